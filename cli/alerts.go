@@ -0,0 +1,373 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AlertRule is a declarative condition on region statuses. A rule fires for a region once
+// its condition has held for the rule's duration, and resolves when it stops holding.
+// Exactly one of Status, NotStatus, DiffersFrom and FetchError is set.
+type AlertRule struct {
+	Name        string        `toml:"name"`
+	Regions     []string      `toml:"regions"`      // regions to check, default all
+	Status      []string      `toml:"status"`       // holds while the status is one of these
+	NotStatus   []string      `toml:"not_status"`   // holds while the status is none of these
+	DiffersFrom string        `toml:"differs_from"` // holds while the status differs from this region's
+	FetchError  bool          `toml:"fetch_error"`  // holds while the region can't be fetched
+	For         time.Duration `toml:"for"`          // how long the condition must hold before firing
+}
+
+// Alert states reported to notifiers
+const (
+	alertFiring   = "firing"
+	alertResolved = "resolved"
+)
+
+// validateAlertRule checks an alert rule from the config file
+func validateAlertRule(r AlertRule, known func(string) bool) error {
+	if r.Name == "" {
+		return errors.New("alert is missing a name")
+	}
+
+	conditions := 0
+	for _, set := range []bool{len(r.Status) > 0, len(r.NotStatus) > 0, r.DiffersFrom != "", r.FetchError} {
+		if set {
+			conditions++
+		}
+	}
+	if conditions != 1 {
+		return fmt.Errorf("alert %q must set exactly one of status, not_status, differs_from or fetch_error", r.Name)
+	}
+
+	if r.For < 0 {
+		return fmt.Errorf("alert %q: for must not be negative", r.Name)
+	}
+	if r.DiffersFrom != "" && !known(r.DiffersFrom) {
+		return fmt.Errorf("alert %q: unknown region %q in differs_from", r.Name, r.DiffersFrom)
+	}
+	for _, region := range r.Regions {
+		if !known(region) {
+			return fmt.Errorf("alert %q: unknown region %q", r.Name, region)
+		}
+	}
+	return nil
+}
+
+// targets returns the regions r is evaluated for. By default that's every one of regions,
+// except the one a differs_from rule compares against.
+func (r AlertRule) targets(regions []string) []string {
+	if len(r.Regions) > 0 {
+		return r.Regions
+	}
+	var targets []string
+	for _, region := range regions {
+		if region != r.DiffersFrom {
+			targets = append(targets, region)
+		}
+	}
+	return targets
+}
+
+// holds reports whether r's condition holds for region. known is false when the statuses
+// needed to tell haven't been fetched, in which case the alert's state is left as it is.
+func (r AlertRule) holds(region string, statuses map[string]statusResult) (holds, known bool) {
+	result, ok := statuses[region]
+	if !ok {
+		return false, false
+	}
+	if r.FetchError {
+		return result.err != nil, true
+	}
+	if result.err != nil {
+		return false, false
+	}
+
+	switch {
+	case len(r.Status) > 0:
+		return containsFold(r.Status, result.status), true
+	case len(r.NotStatus) > 0:
+		return !containsFold(r.NotStatus, result.status), true
+	default:
+		reference, ok := statuses[r.DiffersFrom]
+		if !ok || reference.err != nil {
+			return false, false
+		}
+		return !strings.EqualFold(result.status, reference.status), true
+	}
+}
+
+// condition describes r's condition, e.g. "is not complete"
+func (r AlertRule) condition() string {
+	switch {
+	case len(r.Status) > 0:
+		return "is " + strings.Join(r.Status, " or ")
+	case len(r.NotStatus) > 0:
+		return "is not " + strings.Join(r.NotStatus, " or ")
+	case r.DiffersFrom != "":
+		return "differs from " + regionLabel(r.DiffersFrom)
+	}
+	return "can't be fetched"
+}
+
+// containsFold reports whether values contains s, ignoring case
+func containsFold(values []string, s string) bool {
+	return slices.ContainsFunc(values, func(v string) bool { return strings.EqualFold(v, s) })
+}
+
+// alertState tracks a rule whose condition holds for a region, as stored in alerts.json
+type alertState struct {
+	Rule    string    `json:"rule"`
+	Region  string    `json:"region"`
+	Since   time.Time `json:"since"` // when the condition started holding
+	Firing  bool      `json:"firing"`
+	FiredAt time.Time `json:"firedAt,omitzero"`
+}
+
+// alertKey identifies a rule's alert for a region
+func alertKey(rule, region string) string {
+	return rule + "/" + region
+}
+
+// readAlertStates reads the stored alert states, keyed by alertKey
+func readAlertStates(path string) (map[string]alertState, error) {
+	states := make(map[string]alertState)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return states, nil
+		}
+		return nil, fmt.Errorf("failed to read alerts: %w", err)
+	}
+
+	var stored []alertState
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return states, nil // Corrupt state, start again
+	}
+	for _, state := range stored {
+		states[alertKey(state.Rule, state.Region)] = state
+	}
+	return states, nil
+}
+
+// firingAlerts returns the alerts currently firing, ordered by when they fired
+func firingAlerts(path string) ([]alertState, error) {
+	states, err := readAlertStates(path)
+	if err != nil {
+		return nil, err
+	}
+	var firing []alertState
+	for _, state := range states {
+		if state.Firing {
+			firing = append(firing, state)
+		}
+	}
+	sort.Slice(firing, func(i, j int) bool {
+		if !firing[i].FiredAt.Equal(firing[j].FiredAt) {
+			return firing[i].FiredAt.Before(firing[j].FiredAt)
+		}
+		return alertKey(firing[i].Rule, firing[i].Region) < alertKey(firing[j].Rule, firing[j].Region)
+	})
+	return firing, nil
+}
+
+// describeAlert formats a firing alert for the status display, e.g.
+// "ALERT deploy-stuck: Status is not complete since 09:30"
+func describeAlert(cfg *Config, alert alertState) string {
+	condition := "fired"
+	for _, rule := range cfg.Alerts {
+		if rule.Name == alert.Rule {
+			condition = rule.condition()
+		}
+	}
+	return fmt.Sprintf("ALERT %s: %s %s since %s", alert.Rule, cfg.Label(alert.Region), condition, alert.Since.Local().Format("15:04"))
+}
+
+// alertEvent is an alert firing or resolving, as sent to notifiers
+type alertEvent struct {
+	Rule      string
+	Region    string
+	State     string // alertFiring or alertResolved
+	Condition string // the rule's condition, e.g. "is not complete"
+	Status    string // the region's status, or its fetch error
+	Since     time.Time
+	At        time.Time
+}
+
+// summary describes the alert without its rule name, e.g.
+// "Status is not complete for 1h0m0s (testing)"
+func (ev alertEvent) summary() string {
+	label := regionLabel(ev.Region)
+	if ev.State == alertResolved {
+		return fmt.Sprintf("%s is now %s, after %s", label, ev.Status, formatDuration(ev.At.Sub(ev.Since)))
+	}
+	text := label + " " + ev.Condition
+	if held := ev.At.Sub(ev.Since); held >= time.Second {
+		text += " for " + formatDuration(held)
+	}
+	return fmt.Sprintf("%s (%s)", text, ev.Status)
+}
+
+// Text is a human-readable description of the event, e.g.
+// "deploy-stuck firing: Status is not complete for 1h0m0s (testing)"
+func (ev alertEvent) Text() string {
+	return fmt.Sprintf("%s %s: %s", ev.Rule, ev.State, ev.summary())
+}
+
+// alertEngine evaluates alert rules against the cached statuses. Alert states are stored in
+// a file shared between processes, so an alert fires and resolves once even when another
+// process takes over fetching.
+type alertEngine struct {
+	rules     []AlertRule
+	path      string
+	now       func() time.Time
+	notifiers []func(alertEvent)
+}
+
+// newAlertEngine creates an engine for rules storing alert states at path
+func newAlertEngine(rules []AlertRule, path string) *alertEngine {
+	return &alertEngine{rules: rules, path: path, now: time.Now}
+}
+
+// evaluate updates the alert states from statuses and sends each alert that fires or
+// resolves to the notifiers
+func (e *alertEngine) evaluate(statuses map[string]statusResult) error {
+	var events []alertEvent
+	err := withFileLock(e.path+".lock", func() error {
+		states, err := readAlertStates(e.path)
+		if err != nil {
+			return err
+		}
+		events = e.step(states, statuses)
+		return e.write(states)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, ev := range events {
+		for _, notify := range e.notifiers {
+			notify(ev)
+		}
+	}
+	return nil
+}
+
+// step advances states to the current time and returns the alerts that fired or resolved.
+// States of rules no longer in the config are dropped without notifying.
+func (e *alertEngine) step(states map[string]alertState, statuses map[string]statusResult) []alertEvent {
+	now := e.now()
+	var events []alertEvent
+	seen := make(map[string]bool)
+	regions := currentConfig().RegionIDs()
+
+	for _, rule := range e.rules {
+		for _, region := range rule.targets(regions) {
+			key := alertKey(rule.Name, region)
+			seen[key] = true
+
+			holds, known := rule.holds(region, statuses)
+			if !known {
+				continue
+			}
+			state, tracked := states[key]
+			if !holds {
+				if tracked && state.Firing {
+					events = append(events, newAlertEvent(rule, state, alertResolved, statuses[region], now))
+				}
+				delete(states, key)
+				continue
+			}
+
+			if !tracked {
+				state = alertState{Rule: rule.Name, Region: region, Since: now}
+			}
+			if !state.Firing && now.Sub(state.Since) >= rule.For {
+				state.Firing = true
+				state.FiredAt = now
+				events = append(events, newAlertEvent(rule, state, alertFiring, statuses[region], now))
+			}
+			states[key] = state
+		}
+	}
+
+	for key := range states {
+		if !seen[key] {
+			delete(states, key)
+		}
+	}
+	return events
+}
+
+// newAlertEvent describes rule's alert for state changing to alertFiring or alertResolved
+func newAlertEvent(rule AlertRule, state alertState, to string, result statusResult, now time.Time) alertEvent {
+	status := result.status
+	if result.err != nil {
+		status = result.err.Error()
+	}
+	return alertEvent{
+		Rule:      rule.Name,
+		Region:    state.Region,
+		State:     to,
+		Condition: rule.condition(),
+		Status:    status,
+		Since:     state.Since,
+		At:        now,
+	}
+}
+
+// write stores states, ordered so the file is stable between evaluations
+func (e *alertEngine) write(states map[string]alertState) error {
+	keys := sortedKeys(states)
+	stored := make([]alertState, 0, len(keys))
+	for _, key := range keys {
+		stored = append(stored, states[key])
+	}
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(e.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write alerts: %w", err)
+	}
+	return nil
+}
+
+// alertsPath returns the alert state file stored alongside cache
+func alertsPath(cache *StatusCache) string {
+	return filepath.Join(filepath.Dir(cache.filePath), "alerts.json")
+}
+
+// startAlerts evaluates the configured alert rules after every fetch made by this process.
+// Only the process holding the fetcher lease fetches, so alerts are evaluated and sent once
+// however many processes are running. Alerts go to the webhooks in d, if any, and to the
+// Slack channel subscriptions through slack, unless they're silenced, but not to command
+// hooks, which run for status changes. The engine is returned
+// so its rules can be replaced when the config is reloaded.
+func startAlerts(cache *StatusCache, d *webhookDispatcher, slack *subscriptionNotifier) *alertEngine {
+	e := newAlertEngine(currentConfig().Alerts, alertsPath(cache))
+	e.notifiers = append(e.notifiers, cache.Silences().filterAlerts(func(ev alertEvent) {
+		if d != nil {
+			d.enqueueAlert(ev)
+		}
+		slack.enqueueAlert(ev) // Posting can be slow, don't hold up the fetcher
+	}))
+
+	cache.OnUpdate(func(statuses map[string]statusResult) {
+		if len(e.rules) == 0 {
+			return
+		}
+		if err := e.evaluate(statuses); err != nil {
+			fmt.Fprintf(os.Stderr, "Error evaluating alerts: %v\n", err)
+		}
+	})
+	return e
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestAlertEngine creates an engine for rules on a fake clock, recording the events it sends
+func newTestAlertEngine(t *testing.T, rules ...AlertRule) (*alertEngine, *fakeClock, *[]alertEvent) {
+	t.Helper()
+	for _, r := range rules {
+		if err := validateAlertRule(r, func(string) bool { return true }); err != nil {
+			t.Fatalf("invalid rule: %v", err)
+		}
+	}
+
+	clock := &fakeClock{t: time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)}
+	e := newAlertEngine(rules, filepath.Join(t.TempDir(), "alerts.json"))
+	e.now = clock.Now
+	var events []alertEvent
+	e.notifiers = append(e.notifiers, func(ev alertEvent) { events = append(events, ev) })
+	return e, clock, &events
+}
+
+// testStatuses builds fetch results from region and status pairs
+func testStatuses(pairs ...string) map[string]statusResult {
+	statuses := make(map[string]statusResult)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		statuses[pairs[i]] = statusResult{region: pairs[i], status: pairs[i+1]}
+	}
+	return statuses
+}
+
+// evaluateAt advances clock by d and evaluates statuses
+func evaluateAt(t *testing.T, e *alertEngine, clock *fakeClock, d time.Duration, statuses map[string]statusResult) {
+	t.Helper()
+	clock.Advance(d)
+	if err := e.evaluate(statuses); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestAlertEngine_FiresAfterDurationOnce(t *testing.T) {
+	e, clock, events := newTestAlertEngine(t, AlertRule{Name: "deploy-stuck", Regions: []string{"overall"}, NotStatus: []string{"complete"}, For: time.Hour})
+
+	evaluateAt(t, e, clock, 0, testStatuses("overall", "testing"))
+	evaluateAt(t, e, clock, 59*time.Minute, testStatuses("overall", "deploy"))
+	if len(*events) != 0 {
+		t.Fatalf("expected nothing to fire before an hour, got %+v", *events)
+	}
+
+	evaluateAt(t, e, clock, time.Minute, testStatuses("overall", "deploy"))
+	evaluateAt(t, e, clock, 10*time.Minute, testStatuses("overall", "deploy"))
+	if len(*events) != 1 {
+		t.Fatalf("expected the alert to fire once, got %+v", *events)
+	}
+	fired := (*events)[0]
+	if fired.State != alertFiring || fired.Region != "overall" || !fired.At.Equal(clock.Now().Add(-10*time.Minute)) {
+		t.Errorf("unexpected firing event %+v", fired)
+	}
+	if fired.Text() != "deploy-stuck firing: Status is not complete for 1h0m0s (deploy)" {
+		t.Errorf("unexpected text %q", fired.Text())
+	}
+
+	evaluateAt(t, e, clock, 5*time.Minute, testStatuses("overall", "Complete"))
+	if len(*events) != 2 {
+		t.Fatalf("expected the alert to resolve, got %+v", *events)
+	}
+	if resolved := (*events)[1]; resolved.State != alertResolved || resolved.Text() != "deploy-stuck resolved: Status is now Complete, after 1h15m0s" {
+		t.Errorf("unexpected resolved event %+v: %q", resolved, resolved.Text())
+	}
+}
+
+func TestAlertEngine_ResetsWhenConditionClearsBeforeFiring(t *testing.T) {
+	e, clock, events := newTestAlertEngine(t, AlertRule{Name: "deploy-stuck", Regions: []string{"overall"}, NotStatus: []string{"complete"}, For: time.Hour})
+
+	evaluateAt(t, e, clock, 0, testStatuses("overall", "testing"))
+	evaluateAt(t, e, clock, 50*time.Minute, testStatuses("overall", "complete"))
+	evaluateAt(t, e, clock, time.Minute, testStatuses("overall", "pr"))
+	evaluateAt(t, e, clock, 30*time.Minute, testStatuses("overall", "pr"))
+
+	if len(*events) != 0 {
+		t.Errorf("expected the duration to restart, got %+v", *events)
+	}
+}
+
+func TestAlertEngine_AnyRegionFiresPerRegion(t *testing.T) {
+	e, clock, events := newTestAlertEngine(t, AlertRule{Name: "tests-failed", Status: []string{"testfail"}})
+
+	evaluateAt(t, e, clock, 0, testStatuses("overall", "testing", "au", "testfail", "us", "testing"))
+	evaluateAt(t, e, clock, time.Minute, testStatuses("overall", "testing", "au", "testfail", "us", "TestFail"))
+	evaluateAt(t, e, clock, time.Minute, testStatuses("overall", "testing", "au", "testfail", "us", "TestFail"))
+
+	var fired []string
+	for _, ev := range *events {
+		fired = append(fired, ev.Region+" "+ev.State)
+	}
+	if strings.Join(fired, ",") != "au firing,us firing" {
+		t.Errorf("expected au then us to fire once each, got %v", fired)
+	}
+	if text := (*events)[0].Text(); text != "tests-failed firing: AU is testfail (testfail)" {
+		t.Errorf("unexpected text %q", text)
+	}
+}
+
+func TestAlertEngine_DiffersFrom(t *testing.T) {
+	e, clock, events := newTestAlertEngine(t, AlertRule{Name: "lagging", DiffersFrom: "overall", For: 15 * time.Minute})
+
+	evaluateAt(t, e, clock, 0, testStatuses("overall", "deploy", "au", "deploy", "us", "testok"))
+	evaluateAt(t, e, clock, 15*time.Minute, testStatuses("overall", "deploy", "au", "deploy", "us", "testok"))
+
+	if len(*events) != 1 || (*events)[0].Region != "us" {
+		t.Fatalf("expected only us to fire, got %+v", *events)
+	}
+	if text := (*events)[0].Text(); text != "lagging firing: US differs from Status for 15m0s (testok)" {
+		t.Errorf("unexpected text %q", text)
+	}
+
+	evaluateAt(t, e, clock, time.Minute, testStatuses("overall", "complete", "au", "complete", "us", "complete"))
+	if len(*events) != 2 || (*events)[1].State != alertResolved {
+		t.Errorf("expected us to resolve, got %+v", *events)
+	}
+}
+
+func TestAlertEngine_FetchErrors(t *testing.T) {
+	e, clock, events := newTestAlertEngine(t,
+		AlertRule{Name: "fetch-failing", FetchError: true, For: 5 * time.Minute},
+		AlertRule{Name: "deploy-stuck", Regions: []string{"overall"}, NotStatus: []string{"complete"}, For: 10 * time.Minute},
+	)
+	failing := map[string]statusResult{"overall": {region: "overall", err: errors.New("HTTP 503")}}
+
+	evaluateAt(t, e, clock, 0, testStatuses("overall", "testing"))
+	evaluateAt(t, e, clock, time.Minute, failing)
+	evaluateAt(t, e, clock, 5*time.Minute, failing)
+	if len(*events) != 1 || (*events)[0].Rule != "fetch-failing" || (*events)[0].Status != "HTTP 503" {
+		t.Fatalf("expected fetch-failing to fire, got %+v", *events)
+	}
+
+	// A failed fetch says nothing about the status, so deploy-stuck kept counting
+	evaluateAt(t, e, clock, 4*time.Minute, testStatuses("overall", "testing"))
+	var got []string
+	for _, ev := range *events {
+		got = append(got, ev.Rule+" "+ev.State)
+	}
+	if strings.Join(got, ",") != "fetch-failing firing,fetch-failing resolved,deploy-stuck firing" {
+		t.Errorf("unexpected events %v", got)
+	}
+}
+
+func TestAlertEngine_StatePersistsAcrossEngines(t *testing.T) {
+	rule := AlertRule{Name: "deploy-stuck", Regions: []string{"overall"}, NotStatus: []string{"complete"}, For: time.Hour}
+	e, clock, events := newTestAlertEngine(t, rule)
+
+	evaluateAt(t, e, clock, 0, testStatuses("overall", "testing"))
+	evaluateAt(t, e, clock, time.Hour, testStatuses("overall", "testing"))
+
+	// Another process takes over fetching with the same state file
+	other := newAlertEngine([]AlertRule{rule}, e.path)
+	other.now = clock.Now
+	var otherEvents []alertEvent
+	other.notifiers = append(other.notifiers, func(ev alertEvent) { otherEvents = append(otherEvents, ev) })
+
+	evaluateAt(t, other, clock, time.Minute, testStatuses("overall", "testing"))
+	if len(*events) != 1 || len(otherEvents) != 0 {
+		t.Fatalf("expected the alert to fire once across processes, got %+v and %+v", *events, otherEvents)
+	}
+
+	evaluateAt(t, other, clock, time.Minute, testStatuses("overall", "complete"))
+	if len(otherEvents) != 1 || otherEvents[0].State != alertResolved {
+		t.Errorf("expected the other process to resolve the alert, got %+v", otherEvents)
+	}
+}
+
+func TestAlertEngine_DropsRemovedRules(t *testing.T) {
+	e, clock, _ := newTestAlertEngine(t, AlertRule{Name: "tests-failed", Status: []string{"testfail"}})
+	evaluateAt(t, e, clock, 0, testStatuses("au", "testfail"))
+
+	e.rules = []AlertRule{{Name: "other", FetchError: true}}
+	var notified []alertEvent
+	e.notifiers = []func(alertEvent){func(ev alertEvent) { notified = append(notified, ev) }}
+	evaluateAt(t, e, clock, time.Minute, testStatuses("au", "testfail"))
+
+	states, err := readAlertStates(e.path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(states) != 0 || len(notified) != 0 {
+		t.Errorf("expected the removed rule's alert to be dropped quietly, got %+v and %+v", states, notified)
+	}
+}
+
+func TestFiringAlerts(t *testing.T) {
+	e, clock, _ := newTestAlertEngine(t,
+		AlertRule{Name: "tests-failed", Status: []string{"testfail"}},
+		AlertRule{Name: "deploy-stuck", Regions: []string{"overall"}, NotStatus: []string{"complete"}, For: time.Hour},
+	)
+	evaluateAt(t, e, clock, 0, testStatuses("overall", "testing", "us", "testfail"))
+	evaluateAt(t, e, clock, time.Minute, testStatuses("overall", "testing", "us", "testfail", "au", "testfail"))
+
+	firing, err := firingAlerts(e.path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for _, alert := range firing {
+		got = append(got, alertKey(alert.Rule, alert.Region))
+	}
+	if strings.Join(got, ",") != "tests-failed/us,tests-failed/au" {
+		t.Errorf("expected firing alerts in the order they fired, got %v", got)
+	}
+
+	// The pending deploy-stuck alert is stored too, so its duration survives restarts
+	data, _ := os.ReadFile(e.path)
+	var stored []alertState
+	json.Unmarshal(data, &stored)
+	if len(stored) != 3 {
+		t.Errorf("expected 3 stored alert states, got %s", data)
+	}
+}
+
+func TestStartAlerts_EvaluatesEveryUpdate(t *testing.T) {
+	withConfig(t, func(cfg *Config) {
+		cfg.Alerts = []AlertRule{{Name: "tests-failed", Status: []string{"testfail"}}}
+	})
+
+	receiver := newWebhookReceiver(t)
+	d, _ := newTestDispatcher(t, WebhookConfig{URL: receiver.server.URL})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.run(ctx)
+
+	cache := NewStatusCacheWithPath(filepath.Join(t.TempDir(), "statuses.json"))
+	startAlerts(cache, d, newSubscriptionNotifier(cache))
+
+	cache.UpdateAll(testStatuses("au", "testfail"))
+	cache.UpdateAll(testStatuses("au", "testfail")) // Unchanged, still evaluated but already firing
+	cache.UpdateAll(testStatuses("au", "testok"))
+
+	bodies := receiver.waitForBodies(t, 2)
+	var states []string
+	for _, body := range bodies {
+		var payload webhookPayload
+		if err := json.Unmarshal([]byte(body), &payload); err != nil {
+			t.Fatalf("invalid JSON body: %v", err)
+		}
+		if payload.Event != "alert" || payload.Alert != "tests-failed" || payload.Region != "au" {
+			t.Errorf("unexpected payload %+v", payload)
+		}
+		states = append(states, payload.State)
+	}
+	if strings.Join(states, ",") != "firing,resolved" {
+		t.Errorf("expected firing then resolved, got %v", states)
+	}
+}
+
+func TestValidateAlertRule(t *testing.T) {
+	known := func(region string) bool { return region == "overall" || region == "au" }
+
+	valid := []AlertRule{
+		{Name: "stuck", Regions: []string{"overall"}, NotStatus: []string{"complete"}, For: time.Hour},
+		{Name: "failed", Status: []string{"testfail"}},
+		{Name: "lagging", Regions: []string{"au"}, DiffersFrom: "overall"},
+		{Name: "fetch", FetchError: true, For: 5 * time.Minute},
+	}
+	for _, r := range valid {
+		if err := validateAlertRule(r, known); err != nil {
+			t.Errorf("%s: unexpected error: %v", r.Name, err)
+		}
+	}
+
+	invalid := map[string]AlertRule{
+		"missing name":        {Status: []string{"testfail"}},
+		"no condition":        {Name: "x"},
+		"two conditions":      {Name: "x", Status: []string{"testfail"}, FetchError: true},
+		"negative for":        {Name: "x", FetchError: true, For: -time.Minute},
+		"unknown region":      {Name: "x", FetchError: true, Regions: []string{"eu"}},
+		"unknown differsFrom": {Name: "x", DiffersFrom: "eu"},
+	}
+	for name, r := range invalid {
+		if err := validateAlertRule(r, known); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// cacheWatchInterval is how often the cache files are checked for other processes' writes
+const cacheWatchInterval = time.Second
+
+// cacheWatcher signals the display when what it shows changes on disk. UpdateAll in this
+// process signals as soon as it finishes. Writes by other processes (the fetcher holding the
+// lease, or an ack) are noticed by polling each file's identity, size and modification time;
+// atomic writes replace the file, so every write is seen.
+type cacheWatcher struct {
+	mu      sync.Mutex
+	paths   []string
+	stats   map[string]os.FileInfo
+	changed chan struct{}
+}
+
+// newCacheWatcher creates a watcher for cache and the alert states and silences stored
+// alongside it
+func newCacheWatcher(cache *StatusCache) *cacheWatcher {
+	w := &cacheWatcher{
+		paths:   []string{cache.filePath, alertsPath(cache), cache.Silences().path},
+		stats:   make(map[string]os.FileInfo),
+		changed: make(chan struct{}, 1),
+	}
+	w.scan()
+	cache.OnUpdate(func(map[string]statusResult) { w.notify() })
+	return w
+}
+
+// Changed returns a channel that receives after something changed. Changes made before the
+// receiver catches up are coalesced into one.
+func (w *cacheWatcher) Changed() <-chan struct{} {
+	return w.changed
+}
+
+// notify signals a change made by this process. The files are re-read so the next poll
+// doesn't signal it again.
+func (w *cacheWatcher) notify() {
+	w.scan()
+	w.signal()
+}
+
+// poll signals if any file changed since it was last seen
+func (w *cacheWatcher) poll() {
+	if w.scan() {
+		w.signal()
+	}
+}
+
+// signal sends on changed unless a change is already pending
+func (w *cacheWatcher) signal() {
+	select {
+	case w.changed <- struct{}{}:
+	default:
+	}
+}
+
+// scan records the current state of every file and reports whether any of them changed
+func (w *cacheWatcher) scan() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	changed := false
+	for _, path := range w.paths {
+		stat, _ := os.Stat(path)
+		if !sameFileState(w.stats[path], stat) {
+			changed = true
+		}
+		w.stats[path] = stat
+	}
+	return changed
+}
+
+// sameFileState reports whether two stats of a path show the same file contents. A nil stat
+// means the file didn't exist.
+func sameFileState(a, b os.FileInfo) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return os.SameFile(a, b) && a.Size() == b.Size() && a.ModTime().Equal(b.ModTime())
+}
+
+// run polls the files until stop is closed
+func (w *cacheWatcher) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(cacheWatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.poll()
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// changedNow reports whether w has a change pending, consuming it
+func changedNow(w *cacheWatcher) bool {
+	select {
+	case <-w.Changed():
+		return true
+	default:
+		return false
+	}
+}
+
+func TestCacheWatcher_SignalsUpdateAllInProcess(t *testing.T) {
+	cache := NewStatusCacheWithPath(filepath.Join(t.TempDir(), "statuses.json"))
+	w := newCacheWatcher(cache)
+
+	if changedNow(w) {
+		t.Fatal("expected no change before any update")
+	}
+	cache.UpdateAll(map[string]statusResult{"au": {region: "au", status: "testing"}})
+	if !changedNow(w) {
+		t.Fatal("expected UpdateAll to signal right away")
+	}
+	w.poll()
+	if changedNow(w) {
+		t.Error("expected the poll not to signal the same write again")
+	}
+
+	// Fetches without changes still signal, so the display knows the fetch finished
+	cache.UpdateAll(map[string]statusResult{"au": {region: "au", status: "testing"}})
+	if !changedNow(w) {
+		t.Error("expected an unchanged fetch to signal")
+	}
+}
+
+func TestCacheWatcher_PollsOtherProcessesWrites(t *testing.T) {
+	cache := NewStatusCacheWithPath(filepath.Join(t.TempDir(), "statuses.json"))
+	w := newCacheWatcher(cache)
+
+	w.poll()
+	if changedNow(w) {
+		t.Fatal("expected no change without writes")
+	}
+
+	other := NewStatusCacheWithPath(cache.filePath)
+	other.UpdateAll(map[string]statusResult{"au": {region: "au", status: "testing"}})
+	other.UpdateAll(map[string]statusResult{"au": {region: "au", status: "testfail"}})
+	w.poll()
+	if !changedNow(w) {
+		t.Fatal("expected another process's write to be noticed")
+	}
+	if changedNow(w) {
+		t.Error("expected the writes to be coalesced into one signal")
+	}
+
+	if _, err := other.Silences().Add(silence{Region: "au", ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	w.poll()
+	if !changedNow(w) {
+		t.Error("expected an acknowledgement to be noticed")
+	}
+}
+
+func TestCacheWatcher_Run(t *testing.T) {
+	cache := NewStatusCacheWithPath(filepath.Join(t.TempDir(), "statuses.json"))
+	w := newCacheWatcher(cache)
+	stop := make(chan struct{})
+	defer close(stop)
+	go w.run(stop)
+
+	NewStatusCacheWithPath(cache.filePath).UpdateAll(map[string]statusResult{"au": {region: "au", status: "testing"}})
+	select {
+	case <-w.Changed():
+	case <-time.After(cacheWatchInterval + 2*time.Second):
+		t.Fatal("expected the change to be noticed by polling")
+	}
+}
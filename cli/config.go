@@ -0,0 +1,305 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+
+	"github.com/BurntSushi/toml"
+)
+
+// RegionConfig describes a single status endpoint
+type RegionConfig struct {
+	ID    string `toml:"id"`
+	Label string `toml:"label"`
+	URL   string `toml:"url"`
+}
+
+// Config holds settings loaded from the config file
+type Config struct {
+	// Order lists region IDs in display order. Regions not listed are not shown or fetched.
+	Order    []string        `toml:"order"`
+	Regions  []RegionConfig  `toml:"regions"`
+	Fetch    RetryPolicy     `toml:"fetch"`
+	Poll     PollPolicy      `toml:"poll"`
+	History  HistoryLimits   `toml:"history"`
+	Slack    SlackConfig     `toml:"slack"`
+	Webhooks []WebhookConfig `toml:"webhooks"`
+	Hooks    HookConfig      `toml:"hooks"`
+	Alerts   []AlertRule     `toml:"alerts"`
+	// On maps status values to commands run when a region changes to that status. Alerts
+	// don't run them.
+	On map[string]string `toml:"on"`
+}
+
+// defaultRegions are the built-in regions used when no config file overrides them
+var defaultRegions = []RegionConfig{
+	{ID: "overall", Label: "Status", URL: "https://content.fcsuite.com/deploy/deploy"},
+	{ID: "au", Label: "AU", URL: "https://content.fcsuite.com/deploy/deploy-au"},
+	{ID: "ca", Label: "CA", URL: "https://content.fcsuite.com/deploy/deploy-ca"},
+	{ID: "or", Label: "OR", URL: "https://content.fcsuite.com/deploy/deploy-or"},
+	{ID: "us", Label: "US", URL: "https://content.fcsuite.com/deploy/deploy-us"},
+}
+
+// DefaultConfig returns the built-in configuration
+func DefaultConfig() *Config {
+	regions := make([]RegionConfig, len(defaultRegions))
+	copy(regions, defaultRegions)
+	return &Config{Regions: regions, Fetch: defaultRetryPolicy, Poll: defaultPollPolicy, History: defaultHistoryLimits, Hooks: defaultHookConfig}
+}
+
+// getConfigPath returns the default config file path using OS-appropriate location
+func getConfigPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(configDir, "csuitebluelight", "config.toml"), nil
+}
+
+// LoadConfig reads the config file at path and merges it over the built-in defaults.
+// If path is empty, the default location is used and a missing file is not an error.
+// Secrets set in the environment override the file.
+func LoadConfig(path string) (*Config, error) {
+	cfg, err := loadConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if secret := os.Getenv("SLACK_SIGNING_SECRET"); secret != "" {
+		cfg.Slack.SigningSecret = secret
+	}
+	return cfg, nil
+}
+
+// loadConfigFile implements LoadConfig without environment overrides
+func loadConfigFile(path string) (*Config, error) {
+	explicit := path != ""
+	if !explicit {
+		defaultPath, err := getConfigPath()
+		if err != nil {
+			return DefaultConfig(), nil
+		}
+		path = defaultPath
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, fs.ErrNotExist) {
+			return DefaultConfig(), nil
+		}
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	return parseConfig(data)
+}
+
+// parseConfig decodes TOML config data and merges it over the built-in defaults
+func parseConfig(data []byte) (*Config, error) {
+	var file Config
+	if err := toml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.Slack = file.Slack
+	if cfg.Slack.WebhookURL != "" {
+		if err := validateWebhookURL(cfg.Slack.WebhookURL); err != nil {
+			return nil, fmt.Errorf("invalid config: slack.webhook_url: %w", err)
+		}
+	}
+
+	cfg.Fetch = cfg.Fetch.merge(file.Fetch)
+	if cfg.Fetch.Attempts < 1 {
+		return nil, errors.New("invalid config: fetch.attempts must be at least 1")
+	}
+	if cfg.Fetch.Backoff < 0 || cfg.Fetch.MaxBackoff < 0 || cfg.Fetch.Deadline < 0 {
+		return nil, errors.New("invalid config: fetch durations must not be negative")
+	}
+
+	cfg.Poll = cfg.Poll.merge(file.Poll)
+	if err := cfg.Poll.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	if file.History.MaxBytes != 0 {
+		cfg.History.MaxBytes = file.History.MaxBytes
+	}
+	if file.History.MaxFiles != 0 {
+		cfg.History.MaxFiles = file.History.MaxFiles
+	}
+	if cfg.History.MaxBytes < 1 || cfg.History.MaxFiles < 1 {
+		return nil, errors.New("invalid config: history.max_bytes and history.max_files must be at least 1")
+	}
+
+	if file.Hooks.Timeout != 0 {
+		cfg.Hooks.Timeout = file.Hooks.Timeout
+	}
+	if cfg.Hooks.Timeout < 0 {
+		return nil, errors.New("invalid config: hooks.timeout must not be negative")
+	}
+	for status, command := range file.On {
+		if strings.TrimSpace(command) == "" {
+			return nil, fmt.Errorf("invalid config: on.%s has an empty command", status)
+		}
+	}
+	cfg.On = file.On
+
+	// Regions with a known ID override the defaults field by field, new IDs are appended
+	for _, r := range file.Regions {
+		if r.ID == "" {
+			return nil, errors.New("invalid config: region is missing an id")
+		}
+		merged := false
+		for i := range cfg.Regions {
+			if cfg.Regions[i].ID != r.ID {
+				continue
+			}
+			if r.Label != "" {
+				cfg.Regions[i].Label = r.Label
+			}
+			if r.URL != "" {
+				cfg.Regions[i].URL = r.URL
+			}
+			merged = true
+			break
+		}
+		if !merged {
+			if r.URL == "" {
+				return nil, fmt.Errorf("invalid config: region %q is missing a url", r.ID)
+			}
+			if r.Label == "" {
+				r.Label = strings.ToUpper(r.ID)
+			}
+			cfg.Regions = append(cfg.Regions, r)
+		}
+	}
+
+	if len(file.Order) > 0 {
+		ordered := make([]RegionConfig, 0, len(file.Order))
+		seen := make(map[string]bool)
+		for _, id := range file.Order {
+			if seen[id] {
+				return nil, fmt.Errorf("invalid config: region %q listed twice in order", id)
+			}
+			seen[id] = true
+			region, ok := cfg.Region(id)
+			if !ok {
+				return nil, fmt.Errorf("invalid config: unknown region %q in order", id)
+			}
+			ordered = append(ordered, region)
+		}
+		cfg.Regions = ordered
+		cfg.Order = file.Order
+	}
+
+	known := func(id string) bool {
+		_, ok := cfg.Region(id)
+		return ok
+	}
+	for _, region := range cfg.Poll.Regions {
+		if !known(region) {
+			return nil, fmt.Errorf("invalid config: unknown region %q in poll.regions", region)
+		}
+	}
+	for _, w := range file.Webhooks {
+		if err := validateWebhook(&w, known); err != nil {
+			return nil, fmt.Errorf("invalid config: %w", err)
+		}
+		cfg.Webhooks = append(cfg.Webhooks, w)
+	}
+
+	names := make(map[string]bool)
+	for _, r := range file.Alerts {
+		if err := validateAlertRule(r, known); err != nil {
+			return nil, fmt.Errorf("invalid config: %w", err)
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("invalid config: alert %q defined twice", r.Name)
+		}
+		names[r.Name] = true
+	}
+	cfg.Alerts = file.Alerts
+
+	return cfg, nil
+}
+
+// Region returns the configuration for a region ID
+func (c *Config) Region(id string) (RegionConfig, bool) {
+	for _, r := range c.Regions {
+		if r.ID == id {
+			return r, true
+		}
+	}
+	return RegionConfig{}, false
+}
+
+// RegionIDs returns the configured region IDs in display order
+func (c *Config) RegionIDs() []string {
+	ids := make([]string, 0, len(c.Regions))
+	for _, r := range c.Regions {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+// Label returns the display label for a region, falling back to its upper-cased ID
+func (c *Config) Label(id string) string {
+	if r, ok := c.Region(id); ok && r.Label != "" {
+		return r.Label
+	}
+	return strings.ToUpper(id)
+}
+
+// activeConfig is the configuration in use. Reloading swaps in a new Config instead of
+// changing the active one, so readers on other goroutines take one Config from
+// currentConfig per render or request and see all of it from the same config file.
+var activeConfig atomic.Pointer[Config]
+
+// currentConfig returns the active configuration. It's shared, so it must not be modified.
+func currentConfig() *Config {
+	return activeConfig.Load()
+}
+
+// applyConfig makes cfg the active configuration. cfg must not be modified afterwards.
+func applyConfig(cfg *Config) {
+	activeConfig.Store(cfg)
+}
+
+// reloadConfig re-reads the config file at path and makes it the active configuration,
+// keeping the interval flags. Webhooks, hooks, history limits and the Slack settings are set
+// up at startup, so they keep their current values; the ones that changed in the file are
+// returned so the caller can say a restart is needed.
+func reloadConfig(path string, intervals pollFlags) ([]string, error) {
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := intervals.override(&cfg.Poll); err != nil {
+		return nil, err
+	}
+
+	active := currentConfig()
+	var restart []string
+	if !reflect.DeepEqual(cfg.Webhooks, active.Webhooks) {
+		restart = append(restart, "webhooks")
+	}
+	if cfg.Hooks != active.Hooks || !maps.Equal(cfg.On, active.On) {
+		restart = append(restart, "hooks")
+	}
+	if cfg.History != active.History {
+		restart = append(restart, "history")
+	}
+	if cfg.Slack != active.Slack {
+		restart = append(restart, "slack")
+	}
+	cfg.Webhooks, cfg.Hooks, cfg.On, cfg.History, cfg.Slack = active.Webhooks, active.Hooks, active.On, active.History, active.Slack
+
+	applyConfig(cfg)
+	return restart, nil
+}
@@ -0,0 +1,410 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseConfig_EmptyUsesDefaults(t *testing.T) {
+	cfg, err := parseConfig([]byte(""))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !reflect.DeepEqual(cfg.Regions, defaultRegions) {
+		t.Errorf("expected default regions, got %+v", cfg.Regions)
+	}
+}
+
+func TestParseConfig_OverridesExistingRegion(t *testing.T) {
+	cfg, err := parseConfig([]byte(`
+[[regions]]
+id = "au"
+url = "https://example.com/au"
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	au, ok := cfg.Region("au")
+	if !ok {
+		t.Fatal("expected to find 'au' region")
+	}
+	if au.URL != "https://example.com/au" {
+		t.Errorf("expected overridden url, got %q", au.URL)
+	}
+	if au.Label != "AU" {
+		t.Errorf("expected default label to be kept, got %q", au.Label)
+	}
+	if len(cfg.Regions) != len(defaultRegions) {
+		t.Errorf("expected %d regions, got %d", len(defaultRegions), len(cfg.Regions))
+	}
+}
+
+func TestParseConfig_AddsNewRegion(t *testing.T) {
+	cfg, err := parseConfig([]byte(`
+[[regions]]
+id = "eu"
+url = "https://example.com/eu"
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	last := cfg.Regions[len(cfg.Regions)-1]
+	if last.ID != "eu" {
+		t.Fatalf("expected 'eu' appended last, got %q", last.ID)
+	}
+	if last.Label != "EU" {
+		t.Errorf("expected label to default to 'EU', got %q", last.Label)
+	}
+}
+
+func TestParseConfig_Order(t *testing.T) {
+	cfg, err := parseConfig([]byte(`
+order = ["overall", "eu", "us"]
+
+[[regions]]
+id = "eu"
+label = "Europe"
+url = "https://example.com/eu"
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var ids []string
+	for _, r := range cfg.Regions {
+		ids = append(ids, r.ID)
+	}
+	expected := []string{"overall", "eu", "us"}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Errorf("expected order %v, got %v", expected, ids)
+	}
+}
+
+func TestParseConfig_FetchOverridesDefaults(t *testing.T) {
+	cfg, err := parseConfig([]byte(`
+[fetch]
+attempts = 5
+deadline = "1m"
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.Fetch.Attempts != 5 {
+		t.Errorf("expected 5 attempts, got %d", cfg.Fetch.Attempts)
+	}
+	if cfg.Fetch.Deadline != time.Minute {
+		t.Errorf("expected 1m deadline, got %v", cfg.Fetch.Deadline)
+	}
+	if cfg.Fetch.Backoff != defaultRetryPolicy.Backoff {
+		t.Errorf("expected default backoff to be kept, got %v", cfg.Fetch.Backoff)
+	}
+}
+
+func TestParseConfig_Poll(t *testing.T) {
+	cfg, err := parseConfig([]byte(`
+[poll]
+active = "20s"
+regions = ["overall", "au"]
+
+[poll.status]
+Deploy = "10s"
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.Poll.Active != 20*time.Second || cfg.Poll.Idle != defaultPollPolicy.Idle {
+		t.Errorf("expected active to be overridden and idle kept, got %+v", cfg.Poll)
+	}
+	if cfg.Poll.Status["deploy"] != 10*time.Second {
+		t.Errorf("expected status intervals keyed in lower case, got %v", cfg.Poll.Status)
+	}
+	if len(cfg.Poll.Regions) != 2 {
+		t.Errorf("expected 2 poll regions, got %v", cfg.Poll.Regions)
+	}
+}
+
+func TestParseConfig_Errors(t *testing.T) {
+	tests := map[string]string{
+		"invalid toml":      `order = [`,
+		"missing id":        "[[regions]]\nurl = \"https://example.com\"",
+		"missing url":       "[[regions]]\nid = \"eu\"",
+		"unknown in order":  `order = ["overall", "eu"]`,
+		"duplicate order":   `order = ["au", "au"]`,
+		"negative attempts": "[fetch]\nattempts = -1",
+		"negative backoff":  "[fetch]\nbackoff = \"-1s\"",
+		"negative interval": "[poll]\nidle = \"-1s\"",
+		"zero status poll":  "[poll.status]\ndeploy = \"0s\"",
+		"unknown poll":      "[poll]\nregions = [\"eu\"]",
+	}
+
+	for name, data := range tests {
+		if _, err := parseConfig([]byte(data)); err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+		}
+	}
+}
+
+func TestLoadConfig_ExplicitPathMustExist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.toml")
+
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("expected error for missing explicit config file")
+	}
+}
+
+func TestLoadConfig_ReadsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	os.WriteFile(path, []byte(`order = ["overall", "au"]`), 0644)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Regions) != 2 {
+		t.Errorf("expected 2 regions, got %d", len(cfg.Regions))
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	defer applyConfig(DefaultConfig())
+
+	applyConfig(&Config{Regions: []RegionConfig{
+		{ID: "overall", Label: "Status", URL: "https://example.com/overall"},
+		{ID: "eu", Label: "EU", URL: "https://example.com/eu"},
+	}})
+
+	cfg := currentConfig()
+	if ids := cfg.RegionIDs(); !reflect.DeepEqual(ids, []string{"overall", "eu"}) {
+		t.Errorf("unexpected regions: %v", ids)
+	}
+	if statusURL("eu") != "https://example.com/eu" {
+		t.Errorf("unexpected url for eu: %q", statusURL("eu"))
+	}
+	if cfg.Label("eu") != "EU" || cfg.Label("apac") != "APAC" {
+		t.Errorf("unexpected labels: %q, %q", cfg.Label("eu"), cfg.Label("apac"))
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	defer applyConfig(DefaultConfig())
+	path := filepath.Join(t.TempDir(), "config.toml")
+	os.WriteFile(path, []byte("[[webhooks]]\nurl = \"https://example.com/a\""), 0644)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	applyConfig(cfg)
+
+	os.WriteFile(path, []byte(`
+order = ["overall", "au"]
+
+[poll]
+idle = "2m"
+
+[[webhooks]]
+url = "https://example.com/b"
+`), 0644)
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	intervals := addPollFlags(fs, true)
+	fs.Parse([]string{"--active-interval", "15s"})
+	restart, err := reloadConfig(path, intervals)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	reloaded := currentConfig()
+	if ids := reloaded.RegionIDs(); !reflect.DeepEqual(ids, []string{"overall", "au"}) {
+		t.Errorf("expected the new regions, got %v", ids)
+	}
+	if reloaded.Poll.Idle != 2*time.Minute || reloaded.Poll.Active != 15*time.Second {
+		t.Errorf("expected the new config with the flags kept, got %+v", reloaded.Poll)
+	}
+	if reloaded.Webhooks[0].URL != "https://example.com/a" {
+		t.Errorf("expected webhooks to keep their startup value, got %+v", reloaded.Webhooks)
+	}
+	if len(cfg.Regions) != 5 {
+		t.Errorf("expected the config read before the reload to be unchanged, got %v", cfg.RegionIDs())
+	}
+	if !reflect.DeepEqual(restart, []string{"webhooks"}) {
+		t.Errorf("expected webhooks to need a restart, got %v", restart)
+	}
+
+	os.WriteFile(path, []byte(`order = ["nowhere"]`), 0644)
+	if _, err := reloadConfig(path, intervals); err == nil {
+		t.Error("expected an error for an invalid config")
+	}
+	if currentConfig() != reloaded {
+		t.Errorf("expected an invalid config to leave the active one alone, got %v", currentConfig().RegionIDs())
+	}
+}
+
+func TestReloadConfig_ConcurrentReaders(t *testing.T) {
+	defer applyConfig(DefaultConfig())
+	path := filepath.Join(t.TempDir(), "config.toml")
+	os.WriteFile(path, []byte(`order = ["overall", "au"]`), 0644)
+	cache := NewStatusCacheWithPath(filepath.Join(t.TempDir(), "statuses.json"))
+
+	// Run with -race: reloading swaps the config while snapshots are being built
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 50 {
+			if _, err := reloadConfig(path, pollFlags{}); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		snapshot := buildSnapshot(currentConfig(), cache)
+		if n := len(snapshot.Regions); n != 2 && n != 5 {
+			t.Fatalf("expected the regions of one config or the other, got %d", n)
+		}
+	}
+}
+
+func TestLoadConfig_SlackSigningSecretFromEnvironment(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	os.WriteFile(path, []byte("[slack]\nsigning_secret = \"from-file\""), 0644)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Slack.SigningSecret != "from-file" {
+		t.Errorf("expected secret from file, got %q", cfg.Slack.SigningSecret)
+	}
+
+	t.Setenv("SLACK_SIGNING_SECRET", "from-env")
+	cfg, err = LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Slack.SigningSecret != "from-env" {
+		t.Errorf("expected environment to override file, got %q", cfg.Slack.SigningSecret)
+	}
+}
+
+func TestLoadConfig_RejectsNonSlackWebhookURL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	os.WriteFile(path, []byte("[slack]\nwebhook_url = \"https://example.com/hook\""), 0644)
+
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "slack.webhook_url") {
+		t.Errorf("expected an invalid webhook_url error, got %v", err)
+	}
+}
+
+func TestParseConfig_Webhooks(t *testing.T) {
+	cfg, err := parseConfig([]byte(`
+[[webhooks]]
+url = "https://chat.example.com/hooks/123"
+format = "slack"
+regions = ["au"]
+
+[[webhooks]]
+name = "pager"
+url = "https://pager.example.com/events"
+template = '{"summary": {{json .Text}}}'
+headers = { Authorization = "Token abc" }
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(cfg.Webhooks) != 2 {
+		t.Fatalf("expected 2 webhooks, got %d", len(cfg.Webhooks))
+	}
+	if cfg.Webhooks[0].Name != "chat.example.com" || cfg.Webhooks[0].Format != webhookFormatSlack {
+		t.Errorf("unexpected first webhook: %+v", cfg.Webhooks[0])
+	}
+	if cfg.Webhooks[1].Headers["Authorization"] != "Token abc" {
+		t.Errorf("expected headers to be parsed, got %+v", cfg.Webhooks[1].Headers)
+	}
+
+	if _, err := parseConfig([]byte("order = [\"au\"]\n[[webhooks]]\nurl = \"https://example.com\"\nregions = [\"us\"]")); err == nil {
+		t.Error("expected error for webhook region not in order")
+	}
+}
+
+func TestParseConfig_Hooks(t *testing.T) {
+	cfg, err := parseConfig([]byte(`
+[hooks]
+timeout = "5s"
+
+[on]
+testfail = "notify-send 'Tests failed'"
+complete = "paplay done.oga"
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.Hooks.Timeout != 5*time.Second {
+		t.Errorf("expected 5s timeout, got %v", cfg.Hooks.Timeout)
+	}
+	if cfg.On["testfail"] != "notify-send 'Tests failed'" || cfg.On["complete"] != "paplay done.oga" {
+		t.Errorf("unexpected hooks: %+v", cfg.On)
+	}
+
+	defaults, _ := parseConfig(nil)
+	if defaults.Hooks.Timeout != defaultHookConfig.Timeout {
+		t.Errorf("expected default timeout, got %v", defaults.Hooks.Timeout)
+	}
+
+	if _, err := parseConfig([]byte("[on]\ntestfail = \" \"")); err == nil {
+		t.Error("expected error for empty hook command")
+	}
+}
+
+func TestParseConfig_Alerts(t *testing.T) {
+	cfg, err := parseConfig([]byte(`
+[[alerts]]
+name = "deploy-stuck"
+regions = ["overall"]
+not_status = ["complete"]
+for = "60m"
+
+[[alerts]]
+name = "region-lagging"
+regions = ["au"]
+differs_from = "overall"
+for = "15m"
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(cfg.Alerts) != 2 {
+		t.Fatalf("expected 2 alerts, got %d", len(cfg.Alerts))
+	}
+	if cfg.Alerts[0].For != time.Hour || cfg.Alerts[0].NotStatus[0] != "complete" {
+		t.Errorf("unexpected first alert: %+v", cfg.Alerts[0])
+	}
+	if cfg.Alerts[1].DiffersFrom != "overall" || cfg.Alerts[1].For != 15*time.Minute {
+		t.Errorf("unexpected second alert: %+v", cfg.Alerts[1])
+	}
+
+	duplicate := "[[alerts]]\nname = \"x\"\nfetch_error = true\n[[alerts]]\nname = \"x\"\nfetch_error = true"
+	if _, err := parseConfig([]byte(duplicate)); err == nil {
+		t.Error("expected error for duplicate alert names")
+	}
+	if _, err := parseConfig([]byte("[[alerts]]\nname = \"x\"")); err == nil {
+		t.Error("expected error for alert without a condition")
+	}
+}
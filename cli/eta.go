@@ -0,0 +1,88 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	etaMinSamples     = 3  // completed cycles through a status needed before estimating
+	etaMaxCycles      = 20 // most recent cycles considered per region
+	etaOverduePercent = 10 // overdue once fewer than this share of past cycles took longer
+)
+
+// etaEstimate is a predicted completion time with a confidence range
+type etaEstimate struct {
+	Expected time.Time // median of comparable past deployments
+	Earliest time.Time // 10th percentile
+	Latest   time.Time // 90th percentile
+	Overdue  bool      // taking longer than nearly all past deployments, no estimate
+}
+
+// estimateCompletion predicts when region will reach complete, based on how long past
+// cycles took to finish after entering status. It returns false when the history doesn't
+// show the region currently in status, or there isn't enough history to estimate from.
+func estimateCompletion(entries []transition, region, status string, now time.Time) (etaEstimate, bool) {
+	// The most recent transition tells us the current status and when it was entered
+	var current *transition
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Region == region {
+			current = &entries[i]
+			break
+		}
+	}
+	if current == nil || !strings.EqualFold(current.To, status) || strings.EqualFold(status, "complete") {
+		return etaEstimate{}, false
+	}
+
+	cycles := buildCycles(entries)[region]
+	if len(cycles) > etaMaxCycles {
+		cycles = cycles[len(cycles)-etaMaxCycles:]
+	}
+
+	// Time from the last entry into status until completion, for each past cycle through it
+	var remaining []time.Duration
+	for _, cycle := range cycles {
+		var enteredAt time.Time
+		for _, entry := range cycle.Entries {
+			if strings.EqualFold(entry.To, status) {
+				enteredAt = entry.ChangedAt
+			}
+		}
+		if !enteredAt.IsZero() {
+			remaining = append(remaining, cycle.End.Sub(enteredAt))
+		}
+	}
+	if len(remaining) < etaMinSamples {
+		return etaEstimate{}, false
+	}
+
+	// Only past cycles that took longer than we've already waited are comparable
+	elapsed := now.Sub(current.ChangedAt)
+	var comparable []time.Duration
+	for _, d := range remaining {
+		if d > elapsed {
+			comparable = append(comparable, d)
+		}
+	}
+	if len(comparable) == 0 || len(comparable)*100 < len(remaining)*etaOverduePercent {
+		return etaEstimate{Overdue: true}, true
+	}
+
+	slices.Sort(comparable)
+	return etaEstimate{
+		Expected: current.ChangedAt.Add(percentile(comparable, 50)),
+		Earliest: current.ChangedAt.Add(percentile(comparable, 10)),
+		Latest:   current.ChangedAt.Add(percentile(comparable, 90)),
+	}, true
+}
+
+// formatETA formats an estimate for display next to a region's status
+func formatETA(eta etaEstimate) string {
+	if eta.Overdue {
+		return "ETA unknown, longer than usual"
+	}
+	return "ETA " + eta.Expected.Local().Format("15:04") +
+		" (" + eta.Earliest.Local().Format("15:04") + "-" + eta.Latest.Local().Format("15:04") + ")"
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// etaHistory returns n past cycles for "overall" where testing took testing[i] minutes
+// and the remaining merge and deploy took 10 minutes, followed by the current cycle
+// entering testing at currentStart
+func etaHistory(testing []int, currentStart time.Time) []transition {
+	var entries []transition
+	at := currentStart.Add(-time.Duration(len(testing)+1) * 24 * time.Hour)
+	for _, minutes := range testing {
+		entries = append(entries, cycleTransitions("overall", at,
+			[]string{"complete", "building", "testing", "deploy", "complete"}, []int{5, minutes, 10, 0})...)
+		at = at.Add(24 * time.Hour)
+	}
+	entries = append(entries,
+		transition{Region: "overall", From: "complete", To: "building", ChangedAt: currentStart.Add(-5 * time.Minute)},
+		transition{Region: "overall", From: "building", To: "testing", ChangedAt: currentStart},
+	)
+	return entries
+}
+
+func TestEstimateCompletion(t *testing.T) {
+	start := time.Date(2026, 1, 30, 12, 0, 0, 0, time.UTC)
+	entries := etaHistory([]int{10, 20, 30, 40, 50}, start)
+
+	eta, ok := estimateCompletion(entries, "overall", "testing", start.Add(time.Minute))
+	if !ok {
+		t.Fatal("expected an estimate")
+	}
+	if eta.Overdue {
+		t.Fatal("expected estimate not to be overdue")
+	}
+
+	// Remaining times after entering testing are 20, 30, 40, 50 and 60 minutes
+	if expected := start.Add(40 * time.Minute); !eta.Expected.Equal(expected) {
+		t.Errorf("expected ETA %v, got %v", expected, eta.Expected)
+	}
+	if !eta.Earliest.Equal(start.Add(20*time.Minute)) || !eta.Latest.Equal(start.Add(60*time.Minute)) {
+		t.Errorf("unexpected range %v - %v", eta.Earliest, eta.Latest)
+	}
+}
+
+func TestEstimateCompletion_OnlyUsesComparableCycles(t *testing.T) {
+	start := time.Date(2026, 1, 30, 12, 0, 0, 0, time.UTC)
+	entries := etaHistory([]int{10, 20, 30, 40, 50}, start)
+
+	// After 35 minutes, only the cycles that took 40+ minutes after testing are comparable
+	eta, ok := estimateCompletion(entries, "overall", "testing", start.Add(35*time.Minute))
+	if !ok || eta.Overdue {
+		t.Fatalf("expected an estimate, got %+v (ok %v)", eta, ok)
+	}
+	if eta.Earliest.Before(start.Add(40 * time.Minute)) {
+		t.Errorf("expected earliest estimate after time already waited, got %v", eta.Earliest)
+	}
+
+	// After 45 minutes, 2 of the 5 past cycles still took longer
+	eta, ok = estimateCompletion(entries, "overall", "testing", start.Add(45*time.Minute))
+	if !ok || eta.Overdue {
+		t.Fatalf("expected an estimate while some past cycles took longer, got %+v (ok %v)", eta, ok)
+	}
+	if eta.Earliest.Before(start.Add(50*time.Minute)) || eta.Latest.After(start.Add(60*time.Minute)) {
+		t.Errorf("expected an estimate from the 50 and 60 minute cycles, got %+v", eta)
+	}
+
+	// After 2 hours, no past cycle took this long
+	eta, ok = estimateCompletion(entries, "overall", "testing", start.Add(2*time.Hour))
+	if !ok || !eta.Overdue {
+		t.Errorf("expected overdue estimate, got %+v (ok %v)", eta, ok)
+	}
+}
+
+func TestEstimateCompletion_FallsBackWithoutHistory(t *testing.T) {
+	start := time.Date(2026, 1, 30, 12, 0, 0, 0, time.UTC)
+
+	if _, ok := estimateCompletion(nil, "overall", "testing", start); ok {
+		t.Error("expected no estimate without history")
+	}
+
+	entries := etaHistory([]int{10, 20}, start)
+	if _, ok := estimateCompletion(entries, "overall", "testing", start); ok {
+		t.Error("expected no estimate with too few cycles")
+	}
+
+	entries = etaHistory([]int{10, 20, 30}, start)
+	if _, ok := estimateCompletion(entries, "overall", "deploy", start); ok {
+		t.Error("expected no estimate when history doesn't match the current status")
+	}
+	if _, ok := estimateCompletion(entries, "au", "testing", start); ok {
+		t.Error("expected no estimate for a region without history")
+	}
+}
+
+func TestFormatETA(t *testing.T) {
+	at := time.Date(2026, 1, 30, 12, 0, 0, 0, time.Local)
+	formatted := formatETA(etaEstimate{Expected: at.Add(30 * time.Minute), Earliest: at.Add(20 * time.Minute), Latest: at.Add(45 * time.Minute)})
+
+	if formatted != "ETA 12:30 (12:20-12:45)" {
+		t.Errorf("unexpected format %q", formatted)
+	}
+	if !strings.Contains(formatETA(etaEstimate{Overdue: true}), "longer than usual") {
+		t.Error("expected overdue message")
+	}
+}
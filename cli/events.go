@@ -0,0 +1,369 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Server-Sent Events timing
+const (
+	eventsPollInterval      = 2 * time.Second  // how often the history is checked for other processes' writes
+	eventsHeartbeatInterval = 15 * time.Second // how often idle streams get a comment to keep them open
+	eventsRetry             = 5 * time.Second  // reconnect delay suggested to clients
+	eventsBufferSize        = 64               // events queued per subscriber before it's dropped
+)
+
+// eventBroker fans status transitions out to Server-Sent Events subscribers. Transitions
+// arrive from this process's UpdateAll and from polling the history log, which picks up
+// writes by a fetcher in another process. Each transition is published once, by ID.
+//
+// Update subscribers are signalled whenever the cache changes at all. Fetch errors aren't
+// transitions, so without this a client would keep showing the last good status.
+type eventBroker struct {
+	mu          sync.Mutex
+	history     *HistoryStore
+	subscribers map[chan transition]struct{}
+	updates     map[chan struct{}]struct{}
+	lastID      int64
+	lastStat    os.FileInfo
+}
+
+// newEventBroker creates a broker that publishes transitions recorded after now
+func newEventBroker(history *HistoryStore) *eventBroker {
+	b := &eventBroker{
+		history:     history,
+		subscribers: make(map[chan transition]struct{}),
+		updates:     make(map[chan struct{}]struct{}),
+	}
+	if entries, err := history.Entries(); err == nil && len(entries) > 0 {
+		b.lastID = entries[len(entries)-1].ID
+	}
+	b.lastStat, _ = os.Stat(history.path)
+	return b
+}
+
+// subscribe registers a new subscriber. The channel is closed if the subscriber falls
+// too far behind; call the returned function to unsubscribe.
+func (b *eventBroker) subscribe() (<-chan transition, func()) {
+	ch := make(chan transition, eventsBufferSize)
+
+	b.mu.Lock()
+	b.subscribers[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// subscribeUpdates registers a subscriber to cache changes. Changes made before the
+// subscriber catches up are coalesced into one; call the returned function to unsubscribe.
+func (b *eventBroker) subscribeUpdates() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	b.mu.Lock()
+	b.updates[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.updates, ch)
+		b.mu.Unlock()
+	}
+}
+
+// publishUpdate signals every update subscriber that the cache changed
+func (b *eventBroker) publishUpdate() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.updates {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// publish sends transitions that haven't been published yet to every subscriber
+func (b *eventBroker) publish(entries []transition) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, entry := range entries {
+		if entry.ID <= b.lastID {
+			continue
+		}
+		b.lastID = entry.ID
+
+		for ch := range b.subscribers {
+			select {
+			case ch <- entry:
+			default:
+				// Too slow to keep up; it can reconnect and replay with Last-Event-ID
+				delete(b.subscribers, ch)
+				close(ch)
+			}
+		}
+	}
+}
+
+// poll publishes transitions written to the history since the last poll
+func (b *eventBroker) poll() {
+	if entries, ok := b.read(); ok {
+		b.publish(entries)
+	}
+}
+
+// skip marks the transitions written to the history since the last poll as published
+// without sending them
+func (b *eventBroker) skip() {
+	entries, ok := b.read()
+	if !ok || len(entries) == 0 {
+		return
+	}
+	b.mu.Lock()
+	b.lastID = max(b.lastID, entries[len(entries)-1].ID)
+	b.mu.Unlock()
+}
+
+// read returns the history's entries if it changed since it was last read
+func (b *eventBroker) read() ([]transition, bool) {
+	stat, err := os.Stat(b.history.path)
+	if err != nil {
+		return nil, false
+	}
+	if b.lastStat != nil && stat.Size() == b.lastStat.Size() && stat.ModTime().Equal(b.lastStat.ModTime()) {
+		return nil, false
+	}
+	b.lastStat = stat
+
+	entries, err := b.history.Entries()
+	if err != nil {
+		return nil, false
+	}
+	return entries, true
+}
+
+// run polls the history until stop is closed
+func (b *eventBroker) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(eventsPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			b.poll()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// forwardUpdates publishes an update each time watcher sees the cache change, until stop
+// is closed
+func (b *eventBroker) forwardUpdates(watcher *cacheWatcher, stop <-chan struct{}) {
+	go watcher.run(stop)
+	for {
+		select {
+		case <-watcher.Changed():
+			b.publishUpdate()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// followTransitions passes fn each transition recorded from now on, once each, whether by
+// this process's UpdateAll or by any other process writing the same history, such as a
+// one-shot check or wait that saw the change first. Transitions from other processes are
+// only passed on while polled reports true, or always if it's nil; passing the fetcher
+// lease's Held handles each transition once however many processes share the history. fn
+// also gets each region's first observation, which isn't a change. fn must not block.
+// Transitions published before ctx is done are still passed on; the returned context is
+// done once they have been, so a runner using it can drain what was queued.
+func followTransitions(ctx context.Context, cache *StatusCache, polled func() bool, fn func([]transition)) context.Context {
+	broker := newEventBroker(cache.History())
+	cache.OnTransitions(broker.publish)
+	events, unsubscribe := broker.subscribe()
+	go func() {
+		ticker := time.NewTicker(eventsPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if polled == nil || polled() {
+					broker.poll()
+				} else {
+					broker.skip()
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// The broker drops a subscriber that falls behind, so when fn can't keep up the
+	// subscription is replaced and the transitions it missed are read from the history
+	lastID := broker.lastID
+	forward := func(entry transition) {
+		if entry.ID > lastID {
+			lastID = entry.ID
+			fn([]transition{entry})
+		}
+	}
+	catchUp := func() {
+		if polled != nil && !polled() {
+			return
+		}
+		missed, err := cache.History().Entries()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading history: %v\n", err)
+			return
+		}
+		for _, entry := range missed {
+			forward(entry)
+		}
+	}
+
+	forwarded, stop := context.WithCancel(context.WithoutCancel(ctx))
+	go func() {
+		defer stop()
+		defer func() { unsubscribe() }()
+		for {
+			select {
+			case entry, ok := <-events:
+				if !ok {
+					unsubscribe()
+					events, unsubscribe = broker.subscribe()
+					catchUp()
+					continue
+				}
+				forward(entry)
+			case <-ctx.Done():
+				for {
+					select {
+					case entry, ok := <-events:
+						if !ok {
+							catchUp()
+							return
+						}
+						forward(entry)
+					default:
+						return
+					}
+				}
+			}
+		}
+	}()
+	return forwarded
+}
+
+// writeEvent writes a transition as a Server-Sent Event
+func writeEvent(w http.ResponseWriter, entry transition) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: transition\ndata: %s\n\n", entry.ID, data)
+	return err
+}
+
+// writeUpdateEvent writes an update event, which carries no ID so it doesn't reset the
+// client's Last-Event-ID
+func writeUpdateEvent(w http.ResponseWriter) error {
+	_, err := fmt.Fprint(w, "event: update\ndata: {}\n\n")
+	return err
+}
+
+// handleEvents streams transitions as Server-Sent Events, and an update event whenever the
+// cache changes. Clients reconnecting with a Last-Event-ID header first get the transitions
+// they missed from the history.
+func (s *server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	var lastEventID int64
+	if header := r.Header.Get("Last-Event-ID"); header != "" {
+		id, err := strconv.ParseInt(header, 10, 64)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid Last-Event-ID")
+			return
+		}
+		lastEventID = id
+	}
+
+	// Subscribe before reading the history so nothing is missed in between
+	events, unsubscribe := s.events.subscribe()
+	defer unsubscribe()
+	updates, unsubscribeUpdates := s.events.subscribeUpdates()
+	defer unsubscribeUpdates()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", eventsRetry.Milliseconds())
+
+	sent := lastEventID
+	if lastEventID > 0 {
+		missed, err := s.cache.History().Query(historyFilter{})
+		if err == nil {
+			for _, entry := range missed {
+				if entry.ID <= sent {
+					continue
+				}
+				if writeEvent(w, entry) != nil {
+					return
+				}
+				sent = entry.ID
+			}
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(s.heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case entry, ok := <-events:
+			if !ok {
+				return
+			}
+			if entry.ID <= sent {
+				continue // Already sent during replay
+			}
+			if writeEvent(w, entry) != nil {
+				return
+			}
+			sent = entry.ID
+			flusher.Flush()
+		case <-updates:
+			if writeUpdateEvent(w) != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sseEvent is a parsed Server-Sent Event
+type sseEvent struct {
+	id      string
+	event   string
+	data    string
+	comment string
+}
+
+// openEvents connects to the events endpoint and returns a channel of parsed events
+func openEvents(t *testing.T, url, lastEventID string) <-chan sseEvent {
+	t.Helper()
+
+	req, _ := http.NewRequest("GET", url+"/api/events", nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected text/event-stream, got %q", ct)
+	}
+
+	events := make(chan sseEvent, 100)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		var ev sseEvent
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				if ev != (sseEvent{}) {
+					events <- ev
+				}
+				ev = sseEvent{}
+			case strings.HasPrefix(line, ":"):
+				ev.comment = strings.TrimSpace(line[1:])
+			case strings.HasPrefix(line, "id: "):
+				ev.id = line[4:]
+			case strings.HasPrefix(line, "event: "):
+				ev.event = line[7:]
+			case strings.HasPrefix(line, "data: "):
+				ev.data = line[6:]
+			}
+		}
+	}()
+	return events
+}
+
+// nextTransition waits for the next transition event, skipping heartbeats and retry hints
+func nextTransition(t *testing.T, events <-chan sseEvent) transition {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				t.Fatal("event stream closed")
+			}
+			if ev.event != "transition" {
+				continue
+			}
+			var entry transition
+			if err := json.Unmarshal([]byte(ev.data), &entry); err != nil {
+				t.Fatalf("invalid event data %q: %v", ev.data, err)
+			}
+			if strconv.FormatInt(entry.ID, 10) != ev.id {
+				t.Errorf("expected event id %d, got %q", entry.ID, ev.id)
+			}
+			return entry
+		case <-timeout:
+			t.Fatal("timed out waiting for transition event")
+		}
+	}
+}
+
+func startEventsServer(t *testing.T) (*httptest.Server, *server, *StatusCache) {
+	t.Helper()
+	srv, cache := newTestServer(t)
+	srv.heartbeatInterval = 20 * time.Millisecond
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	return ts, srv, cache
+}
+
+func TestEvents_StreamsTransitions(t *testing.T) {
+	ts, _, cache := startEventsServer(t)
+	events := openEvents(t, ts.URL, "")
+
+	cache.UpdateAll(map[string]statusResult{"overall": {region: "overall", status: "testok"}})
+
+	entry := nextTransition(t, events)
+	if entry.Region != "overall" || entry.From != "testing" || entry.To != "testok" || entry.ChangedAt.IsZero() {
+		t.Errorf("unexpected transition: %+v", entry)
+	}
+}
+
+func TestEvents_SendsUpdateOnFetchError(t *testing.T) {
+	ts, srv, cache := startEventsServer(t)
+	stop := make(chan struct{})
+	defer close(stop)
+	go srv.events.forwardUpdates(srv.changes, stop)
+	events := openEvents(t, ts.URL, "")
+
+	cache.UpdateAll(map[string]statusResult{"au": {region: "au", err: errors.New("HTTP 503")}})
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				t.Fatal("event stream closed")
+			}
+			if ev.event == "transition" {
+				t.Errorf("expected no transition for a fetch error, got %+v", ev)
+			}
+			if ev.event == "update" {
+				if ev.id != "" {
+					t.Errorf("expected update event without an id, got %q", ev.id)
+				}
+				return
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for update event")
+		}
+	}
+}
+
+func TestEvents_Heartbeats(t *testing.T) {
+	ts, _, _ := startEventsServer(t)
+	events := openEvents(t, ts.URL, "")
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case ev := <-events:
+			if ev.comment == "heartbeat" {
+				return
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for heartbeat")
+		}
+	}
+}
+
+func TestEvents_ReplaysFromLastEventID(t *testing.T) {
+	ts, _, cache := startEventsServer(t)
+
+	cache.UpdateAll(map[string]statusResult{"overall": {region: "overall", status: "testok"}})
+	cache.UpdateAll(map[string]statusResult{"overall": {region: "overall", status: "merging"}})
+
+	entries, _ := cache.History().Entries()
+	if len(entries) < 3 {
+		t.Fatalf("expected at least 3 history entries, got %d", len(entries))
+	}
+	missedFrom := entries[len(entries)-3]
+
+	events := openEvents(t, ts.URL, strconv.FormatInt(missedFrom.ID, 10))
+
+	first := nextTransition(t, events)
+	second := nextTransition(t, events)
+	if first.To != "testok" || second.To != "merging" {
+		t.Errorf("expected replay of testok then merging, got %q then %q", first.To, second.To)
+	}
+
+	// Live events continue after the replay without duplicates
+	cache.UpdateAll(map[string]statusResult{"overall": {region: "overall", status: "deploy"}})
+	if live := nextTransition(t, events); live.To != "deploy" {
+		t.Errorf("expected live deploy event, got %+v", live)
+	}
+}
+
+func TestEvents_InvalidLastEventID(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	req := httptest.NewRequest("GET", "/api/events", nil)
+	req.Header.Set("Last-Event-ID", "abc")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestEvents_ManySubscribers(t *testing.T) {
+	ts, _, cache := startEventsServer(t)
+
+	const subscribers = 50
+	streams := make([]<-chan sseEvent, subscribers)
+	for i := range streams {
+		streams[i] = openEvents(t, ts.URL, "")
+	}
+
+	cache.UpdateAll(map[string]statusResult{"au": {region: "au", status: "deploy"}})
+
+	var wg sync.WaitGroup
+	for _, events := range streams {
+		wg.Add(1)
+		go func(events <-chan sseEvent) {
+			defer wg.Done()
+			if entry := nextTransition(t, events); entry.Region != "au" || entry.To != "deploy" {
+				t.Errorf("unexpected transition: %+v", entry)
+			}
+		}(events)
+	}
+	wg.Wait()
+}
+
+func TestEventBroker_PollsHistoryFromOtherProcesses(t *testing.T) {
+	_, srv, cache := startEventsServer(t)
+	events, unsubscribe := srv.events.subscribe()
+	defer unsubscribe()
+
+	// Another process's cache writes to the same history
+	other := NewStatusCacheWithPath(cache.filePath)
+	other.UpdateAll(map[string]statusResult{"overall": {region: "overall", status: "deploy"}})
+
+	srv.events.poll()
+
+	select {
+	case entry := <-events:
+		if entry.To != "deploy" {
+			t.Errorf("unexpected transition: %+v", entry)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected transition from polling the history")
+	}
+
+	// Polling again publishes nothing new
+	srv.events.poll()
+	select {
+	case entry := <-events:
+		t.Errorf("expected no duplicate, got %+v", entry)
+	default:
+	}
+}
+
+func TestEventBroker_DropsSlowSubscribers(t *testing.T) {
+	_, srv, _ := startEventsServer(t)
+	events, unsubscribe := srv.events.subscribe()
+	defer unsubscribe()
+
+	var entries []transition
+	for i := 1; i <= eventsBufferSize+1; i++ {
+		entries = append(entries, transition{ID: srv.events.lastID + int64(i), Region: "au"})
+	}
+	srv.events.publish(entries)
+
+	received := 0
+	for range events {
+		received++
+	}
+	if received != eventsBufferSize {
+		t.Errorf("expected %d buffered events before the channel closed, got %d", eventsBufferSize, received)
+	}
+}
+
+func TestFollowTransitions_CatchesUpAfterFallingBehind(t *testing.T) {
+	cache := NewStatusCacheWithPath(filepath.Join(t.TempDir(), "statuses.json"))
+
+	var mu sync.Mutex
+	var received []transition
+	unblock := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	forwarded := followTransitions(ctx, cache, nil, func(entries []transition) {
+		<-unblock // Blocked until every transition has been recorded
+		mu.Lock()
+		received = append(received, entries...)
+		mu.Unlock()
+	})
+
+	total := eventsBufferSize + 6
+	for i := 0; i < total; i++ {
+		cache.UpdateAll(map[string]statusResult{"au": {region: "au", status: []string{"testing", "testok"}[i%2]}})
+	}
+	close(unblock)
+
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(10 * time.Millisecond) {
+		mu.Lock()
+		n := len(received)
+		mu.Unlock()
+		if n >= total || time.Now().After(deadline) {
+			break
+		}
+	}
+	cancel()
+	select {
+	case <-forwarded.Done():
+	case <-time.After(time.Second):
+		t.Fatal("expected forwarding to stop once cancelled")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != total {
+		t.Fatalf("expected %d transitions, got %d", total, len(received))
+	}
+	for i, entry := range received {
+		if entry.ID != int64(i+1) {
+			t.Fatalf("expected transition %d in order, got %+v", i+1, entry)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Process exit codes for a one-shot run
+const (
+	exitOK         = 0 // all regions complete, or no state listed in --fail-on was seen
+	exitError      = 1 // the CLI itself failed, e.g. bad config or cache directory
+	exitUsage      = 2 // invalid flags or arguments
+	exitInProgress = 3 // a region is building, testing, in a PR, or in an unknown state
+	exitFailed     = 4 // a region reported testfail or error
+	exitFetchError = 5 // a region's status couldn't be fetched
+	exitTimeout    = 6 // wait gave up before the deployment finished
+)
+
+// Deployment states a region can be in, derived from classifyStatus
+const (
+	stateComplete   = "complete"
+	stateInProgress = "in-progress"
+	stateFailed     = "failed"
+	stateFetchError = "fetch-error"
+)
+
+// defaultFailOn treats anything other than complete as a failure
+const defaultFailOn = stateInProgress + "," + stateFailed + "," + stateFetchError
+
+// stateExitCodes lists the non-complete states in order of precedence
+var stateExitCodes = []struct {
+	state string
+	code  int
+}{
+	{stateFailed, exitFailed},
+	{stateFetchError, exitFetchError},
+	{stateInProgress, exitInProgress},
+}
+
+// regionState returns the deployment state of a cached result
+func regionState(result statusResult) string {
+	if result.err != nil || result.status == "" {
+		return stateFetchError
+	}
+
+	switch classifyStatus(result.status) {
+	case classRed:
+		return stateFailed
+	case classWhite:
+		if strings.EqualFold(result.status, "complete") {
+			return stateComplete
+		}
+	}
+	return stateInProgress
+}
+
+// parseFailOn parses a comma-separated list of states for --fail-on. "none" disables all.
+func parseFailOn(value string) (map[string]bool, error) {
+	failOn := make(map[string]bool)
+	if strings.TrimSpace(value) == "none" {
+		return failOn, nil
+	}
+
+	for _, part := range strings.Split(value, ",") {
+		state := strings.TrimSpace(part)
+		switch state {
+		case stateInProgress, stateFailed, stateFetchError:
+			failOn[state] = true
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown --fail-on state %q (expected %s, or none)", state, strings.ReplaceAll(defaultFailOn, ",", ", "))
+		}
+	}
+	return failOn, nil
+}
+
+// exitCodeFor returns the exit code for the configured regions' statuses. Acknowledged
+// regions are left out.
+func exitCodeFor(statuses map[string]statusResult, failOn map[string]bool, acked map[string]silence) int {
+	seen := make(map[string]bool)
+	for _, region := range currentConfig().RegionIDs() {
+		if _, ok := acked[region]; ok {
+			continue
+		}
+		seen[regionState(statuses[region])] = true
+	}
+
+	for _, s := range stateExitCodes {
+		if seen[s.state] && failOn[s.state] {
+			return s.code
+		}
+	}
+	return exitOK
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRegionState(t *testing.T) {
+	tests := []struct {
+		result   statusResult
+		expected string
+	}{
+		{statusResult{status: "complete"}, stateComplete},
+		{statusResult{status: "COMPLETE"}, stateComplete},
+		{statusResult{status: "testing"}, stateInProgress},
+		{statusResult{status: "pr"}, stateInProgress},
+		{statusResult{status: "unknown"}, stateInProgress},
+		{statusResult{status: "testfail"}, stateFailed},
+		{statusResult{status: "error"}, stateFailed},
+		{statusResult{err: errors.New("timeout")}, stateFetchError},
+		{statusResult{}, stateFetchError},
+	}
+
+	for _, tt := range tests {
+		if got := regionState(tt.result); got != tt.expected {
+			t.Errorf("regionState(%+v) = %q, expected %q", tt.result, got, tt.expected)
+		}
+	}
+}
+
+func TestParseFailOn(t *testing.T) {
+	failOn, err := parseFailOn(defaultFailOn)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !failOn[stateInProgress] || !failOn[stateFailed] || !failOn[stateFetchError] {
+		t.Errorf("expected all states in default, got %v", failOn)
+	}
+
+	failOn, err = parseFailOn("failed, fetch-error")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if failOn[stateInProgress] || !failOn[stateFailed] || !failOn[stateFetchError] {
+		t.Errorf("unexpected states: %v", failOn)
+	}
+
+	failOn, err = parseFailOn("none")
+	if err != nil || len(failOn) != 0 {
+		t.Errorf("expected no states for 'none', got %v (err %v)", failOn, err)
+	}
+
+	if _, err := parseFailOn("failed,broken"); err == nil {
+		t.Error("expected error for unknown state")
+	}
+}
+
+func TestExitCodeFor(t *testing.T) {
+	allComplete := map[string]statusResult{}
+	for _, region := range currentConfig().RegionIDs() {
+		allComplete[region] = statusResult{region: region, status: "complete"}
+	}
+
+	with := func(region string, result statusResult) map[string]statusResult {
+		statuses := make(map[string]statusResult, len(allComplete))
+		for k, v := range allComplete {
+			statuses[k] = v
+		}
+		statuses[region] = result
+		return statuses
+	}
+
+	failOnAll, _ := parseFailOn(defaultFailOn)
+	failOnFailed, _ := parseFailOn("failed")
+
+	tests := []struct {
+		name     string
+		statuses map[string]statusResult
+		failOn   map[string]bool
+		expected int
+	}{
+		{"all complete", allComplete, failOnAll, exitOK},
+		{"in progress", with("overall", statusResult{status: "testing"}), failOnAll, exitInProgress},
+		{"failed", with("au", statusResult{status: "testfail"}), failOnAll, exitFailed},
+		{"fetch error", with("ca", statusResult{err: errors.New("timeout")}), failOnAll, exitFetchError},
+		{"missing region", map[string]statusResult{}, failOnAll, exitFetchError},
+		{"in progress ignored", with("overall", statusResult{status: "deploy"}), failOnFailed, exitOK},
+		{"failed takes precedence", with("au", statusResult{status: "error"}), failOnFailed, exitFailed},
+	}
+
+	for _, tt := range tests {
+		if got := exitCodeFor(tt.statuses, tt.failOn, nil); got != tt.expected {
+			t.Errorf("%s: expected exit code %d, got %d", tt.name, tt.expected, got)
+		}
+	}
+
+	// Failed outranks fetch errors and in-progress when several are present
+	mixed := with("au", statusResult{status: "testfail"})
+	mixed["ca"] = statusResult{err: errors.New("timeout")}
+	mixed["overall"] = statusResult{status: "testing"}
+	if got := exitCodeFor(mixed, failOnAll, nil); got != exitFailed {
+		t.Errorf("mixed: expected exit code %d, got %d", exitFailed, got)
+	}
+
+	// Acknowledged regions are left out, so the next state takes over
+	acked := map[string]silence{"au": {Region: "au"}}
+	if got := exitCodeFor(mixed, failOnAll, acked); got != exitFetchError {
+		t.Errorf("acknowledged: expected exit code %d, got %d", exitFetchError, got)
+	}
+}
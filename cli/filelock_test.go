@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// hammerEnv names the cache file a helper process should write to
+const hammerEnv = "DEPLOY_STATUS_HAMMER_FILE"
+
+// hammerCache writes changing statuses to the cache at path until deadline
+func hammerCache(path, writer string, deadline time.Time) error {
+	cache := NewStatusCacheWithPath(path)
+	for i := 0; time.Now().Before(deadline); i++ {
+		results := make(map[string]statusResult)
+		for _, region := range currentConfig().RegionIDs() {
+			results[region] = statusResult{region: region, status: fmt.Sprintf("%s-%d", writer, i)}
+		}
+		if err := cache.UpdateAll(results); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TestHelperHammerProcess is run as a subprocess by TestStatusCache_ConcurrentWritersNeverExposePartialFile
+func TestHelperHammerProcess(t *testing.T) {
+	path := os.Getenv(hammerEnv)
+	if path == "" {
+		t.Skip("helper process for TestStatusCache_ConcurrentWritersNeverExposePartialFile")
+	}
+	if err := hammerCache(path, fmt.Sprintf("proc%d", os.Getpid()), time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStatusCache_ConcurrentWritersNeverExposePartialFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "statuses.json")
+	NewStatusCacheWithPath(path).UpdateAll(map[string]statusResult{
+		"overall": {region: "overall", status: "initial"},
+	})
+
+	deadline := time.Now().Add(time.Second)
+
+	var procs []*exec.Cmd
+	for i := 0; i < 3; i++ {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestHelperHammerProcess$")
+		cmd.Env = append(os.Environ(), hammerEnv+"="+path)
+		if err := cmd.Start(); err != nil {
+			t.Fatalf("failed to start helper process: %v", err)
+		}
+		procs = append(procs, cmd)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(writer string) {
+			defer wg.Done()
+			if err := hammerCache(path, writer, deadline); err != nil {
+				errs <- err
+			}
+		}(fmt.Sprintf("goroutine%d", i))
+	}
+
+	// Read continuously while the writers run; every read must be complete JSON
+	reads := 0
+	for time.Now().Before(deadline) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		var statuses map[string]cachedStatus
+		if err := json.Unmarshal(data, &statuses); err != nil {
+			t.Fatalf("observed partial cache file (%d bytes): %v", len(data), err)
+		}
+		reads++
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("writer failed: %v", err)
+	}
+	for _, cmd := range procs {
+		if err := cmd.Wait(); err != nil {
+			t.Errorf("helper process failed: %v", err)
+		}
+	}
+
+	if reads == 0 {
+		t.Error("expected at least one read")
+	}
+
+	leftovers, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".statuses.json.*.tmp"))
+	if len(leftovers) != 0 {
+		t.Errorf("expected temp files to be cleaned up, found %v", leftovers)
+	}
+}
+
+func TestWithFileLock_SerializesAccess(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "test.lock")
+
+	var mu sync.Mutex
+	inside := 0
+	maxInside := 0
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			withFileLock(lockPath, func() error {
+				mu.Lock()
+				inside++
+				maxInside = max(maxInside, inside)
+				mu.Unlock()
+
+				time.Sleep(5 * time.Millisecond)
+
+				mu.Lock()
+				inside--
+				mu.Unlock()
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+
+	if maxInside != 1 {
+		t.Errorf("expected one holder at a time, saw %d", maxInside)
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.json")
+	os.WriteFile(path, []byte("old"), 0644)
+
+	if err := writeFileAtomic(path, []byte("new"), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if string(data) != "new" {
+		t.Errorf("expected 'new', got %q", data)
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("expected only the data file, found %d entries", len(entries))
+	}
+}
@@ -2,10 +2,13 @@ module deploy-status
 
 go 1.25.6
 
-require github.com/fatih/color v1.18.0
+require (
+	github.com/BurntSushi/toml v1.6.0
+	github.com/fatih/color v1.18.0
+	golang.org/x/sys v0.25.0
+)
 
 require (
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
-	golang.org/x/sys v0.25.0 // indirect
 )
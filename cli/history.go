@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// transition records a region's status changing from one value to another
+type transition struct {
+	ID        int64     `json:"id"`
+	Region    string    `json:"region"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	ChangedAt time.Time `json:"changedAt"`
+}
+
+// HistoryLimits bounds how much disk space the history log uses
+type HistoryLimits struct {
+	MaxBytes int64 `toml:"max_bytes"` // rotate the log once it grows past this size
+	MaxFiles int   `toml:"max_files"` // rotated files to keep in addition to the current one
+}
+
+var defaultHistoryLimits = HistoryLimits{MaxBytes: 1 << 20, MaxFiles: 3}
+
+// HistoryStore is an append-only log of status transitions stored as JSON lines.
+// Old entries are rotated into numbered files (history.jsonl.1, .2, ...) and dropped
+// once MaxFiles rotated files exist.
+type HistoryStore struct {
+	mu     sync.Mutex
+	path   string
+	limits HistoryLimits
+}
+
+// historyFilter selects entries returned by HistoryStore.Query
+type historyFilter struct {
+	Region string
+	Since  time.Time
+	Until  time.Time
+	Limit  int // most recent entries to return, 0 for all
+}
+
+// NewHistoryStore creates a HistoryStore writing to path
+func NewHistoryStore(path string, limits HistoryLimits) *HistoryStore {
+	return &HistoryStore{path: path, limits: limits}
+}
+
+// files returns the log file paths oldest first
+func (h *HistoryStore) files() []string {
+	return logFiles(h.path, h.limits.MaxFiles)
+}
+
+// readHistoryFile parses a log file, skipping lines that can't be decoded
+func readHistoryFile(path string) ([]transition, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []transition
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry transition
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue // Partial or corrupt line, skip it
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// entries returns every stored transition oldest first. Callers must hold h.mu and the file lock.
+func (h *HistoryStore) entries() ([]transition, error) {
+	var all []transition
+	for _, path := range h.files() {
+		entries, err := readHistoryFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history: %w", err)
+		}
+		all = append(all, entries...)
+	}
+	return all, nil
+}
+
+// Entries returns every stored transition oldest first
+func (h *HistoryStore) Entries() ([]transition, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var entries []transition
+	err := withFileLock(h.lockPath(), func() error {
+		var err error
+		entries, err = h.entries()
+		return err
+	})
+	return entries, err
+}
+
+// lockPath returns the lock file coordinating access to the log across processes
+func (h *HistoryStore) lockPath() string {
+	return h.path + ".lock"
+}
+
+// Query returns the stored transitions matching f, oldest first
+func (h *HistoryStore) Query(f historyFilter) ([]transition, error) {
+	all, err := h.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []transition
+	for _, entry := range all {
+		if f.Region != "" && entry.Region != f.Region {
+			continue
+		}
+		if !f.Since.IsZero() && entry.ChangedAt.Before(f.Since) {
+			continue
+		}
+		if !f.Until.IsZero() && entry.ChangedAt.After(f.Until) {
+			continue
+		}
+		matched = append(matched, entry)
+	}
+
+	if f.Limit > 0 && len(matched) > f.Limit {
+		matched = matched[len(matched)-f.Limit:]
+	}
+	return matched, nil
+}
+
+// Record appends a transition for each successful result whose status differs from the
+// last recorded status for its region. Regions without any recorded status use fallback,
+// and a region seen for the first time is recorded with an empty From so later
+// transitions have a starting point. Returns the transitions written.
+func (h *HistoryStore) Record(results map[string]statusResult, fallback map[string]string, at time.Time) ([]transition, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var recorded []transition
+	err := withFileLock(h.lockPath(), func() error {
+		var err error
+		recorded, err = h.record(results, fallback, at)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return recorded, nil
+}
+
+// record implements Record. Callers must hold h.mu and the file lock.
+func (h *HistoryStore) record(results map[string]statusResult, fallback map[string]string, at time.Time) ([]transition, error) {
+	// Re-read the log so transitions recorded by other processes aren't duplicated
+	existing, err := h.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	var lastID int64
+	last := make(map[string]string)
+	for _, entry := range existing {
+		last[entry.Region] = entry.To
+		lastID = max(lastID, entry.ID)
+	}
+
+	names := make([]string, 0, len(results))
+	for region := range results {
+		names = append(names, region)
+	}
+	sort.Strings(names)
+
+	var recorded []transition
+	for _, region := range names {
+		result := results[region]
+		if result.err != nil || result.status == "" {
+			continue
+		}
+
+		previous, ok := last[region]
+		if !ok {
+			previous = fallback[region]
+		}
+		if previous == result.status {
+			continue
+		}
+
+		lastID++
+		recorded = append(recorded, transition{
+			ID:        lastID,
+			Region:    region,
+			From:      previous,
+			To:        result.status,
+			ChangedAt: at,
+		})
+	}
+
+	if len(recorded) == 0 {
+		return nil, nil
+	}
+	if err := h.append(recorded); err != nil {
+		return nil, err
+	}
+	return recorded, nil
+}
+
+// append writes entries to the current log file and rotates it if it's grown too large.
+// Callers must hold h.mu and the file lock.
+func (h *HistoryStore) append(entries []transition) error {
+	var buf strings.Builder
+	for _, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to marshal history: %w", err)
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open history: %w", err)
+	}
+	if _, err := io.WriteString(f, buf.String()); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	info, err := f.Stat()
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to stat history: %w", err)
+	}
+
+	if h.limits.MaxBytes > 0 && info.Size() > h.limits.MaxBytes {
+		if err := rotateLog(h.path, h.limits.MaxFiles); err != nil {
+			return fmt.Errorf("failed to rotate history: %w", err)
+		}
+	}
+	return nil
+}
+
+// parseTimeFlag parses an absolute time (RFC 3339 or YYYY-MM-DD) or a duration before now
+func parseTimeFlag(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (expected a duration like 24h, RFC 3339, or YYYY-MM-DD)", value)
+}
+
+// printHistory writes transitions to w, one per line
+func printHistory(w io.Writer, entries []transition) {
+	for _, entry := range entries {
+		label := regionLabel(entry.Region)
+		from := entry.From
+		if from == "" {
+			from = "(first seen)"
+		}
+		fmt.Fprintf(w, "%s  %-10s %s -> %s\n", entry.ChangedAt.Local().Format("Mon Jan 2 15:04:05 2006"), label, from, entry.To)
+	}
+}
+
+// runHistory implements the history subcommand
+func runHistory(args []string) int {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path to config file (default: user config dir)")
+	region := fs.String("region", "", "Only show transitions for this region")
+	since := fs.String("since", "", "Only show transitions after this time or duration ago, e.g. 24h")
+	until := fs.String("until", "", "Only show transitions before this time or duration ago")
+	limit := fs.Int("limit", 20, "Number of most recent transitions to show, 0 for all")
+	output := fs.String("output", outputText, "Output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if *output != outputText && *output != outputJSON {
+		fmt.Fprintf(os.Stderr, "Error: unknown output format %q (expected text or json)\n", *output)
+		return exitUsage
+	}
+
+	now := time.Now()
+	filter := historyFilter{Region: strings.ToLower(*region), Limit: *limit}
+	var err error
+	if filter.Since, err = parseTimeFlag(*since, now); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --since: %v\n", err)
+		return exitUsage
+	}
+	if filter.Until, err = parseTimeFlag(*until, now); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --until: %v\n", err)
+		return exitUsage
+	}
+
+	cache, ok := initialize(*configPath)
+	if !ok {
+		return exitError
+	}
+
+	entries, err := cache.History().Query(filter)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+
+	if *output == outputJSON {
+		if entries == nil {
+			entries = []transition{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(entries); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+			return exitError
+		}
+		return exitOK
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No status transitions recorded")
+		return exitOK
+	}
+	printHistory(os.Stdout, entries)
+	return exitOK
+}
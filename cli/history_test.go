@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestHistory(t *testing.T, limits HistoryLimits) *HistoryStore {
+	t.Helper()
+	return NewHistoryStore(filepath.Join(t.TempDir(), "history.jsonl"), limits)
+}
+
+func statusResults(statuses map[string]string) map[string]statusResult {
+	results := make(map[string]statusResult, len(statuses))
+	for region, status := range statuses {
+		results[region] = statusResult{region: region, status: status}
+	}
+	return results
+}
+
+func TestHistoryStore_RecordsTransitions(t *testing.T) {
+	h := newTestHistory(t, defaultHistoryLimits)
+	at := time.Date(2026, 1, 30, 15, 4, 5, 0, time.UTC)
+
+	recorded, err := h.Record(statusResults(map[string]string{"overall": "testing", "au": "complete"}),
+		map[string]string{"overall": "building", "au": "complete"}, at)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(recorded) != 1 {
+		t.Fatalf("expected 1 transition, got %d", len(recorded))
+	}
+
+	entry := recorded[0]
+	if entry.ID != 1 || entry.Region != "overall" || entry.From != "building" || entry.To != "testing" || !entry.ChangedAt.Equal(at) {
+		t.Errorf("unexpected transition: %+v", entry)
+	}
+
+	// The recorded status takes precedence over the fallback
+	recorded, _ = h.Record(statusResults(map[string]string{"overall": "testok"}),
+		map[string]string{"overall": "building"}, at.Add(time.Minute))
+	if len(recorded) != 1 || recorded[0].From != "testing" || recorded[0].ID != 2 {
+		t.Errorf("unexpected transition: %+v", recorded)
+	}
+}
+
+func TestHistoryStore_RecordsFirstObservationAndSkipsErrors(t *testing.T) {
+	h := newTestHistory(t, defaultHistoryLimits)
+
+	recorded, err := h.Record(map[string]statusResult{
+		"overall": {region: "overall", status: "testing"},
+		"au":      {region: "au", err: errors.New("timeout")},
+	}, map[string]string{"au": "complete"}, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(recorded) != 1 {
+		t.Fatalf("expected 1 transition, got %+v", recorded)
+	}
+	if recorded[0].Region != "overall" || recorded[0].From != "" || recorded[0].To != "testing" {
+		t.Errorf("expected first observation of overall, got %+v", recorded[0])
+	}
+}
+
+func TestHistoryStore_DoesNotDuplicateAcrossStores(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	h1 := NewHistoryStore(path, defaultHistoryLimits)
+	h2 := NewHistoryStore(path, defaultHistoryLimits)
+
+	results := statusResults(map[string]string{"overall": "deploy"})
+	fallback := map[string]string{"overall": "merging"}
+
+	h1.Record(results, fallback, time.Now())
+	recorded, _ := h2.Record(results, fallback, time.Now())
+
+	if len(recorded) != 0 {
+		t.Errorf("expected second store to see existing transition, got %+v", recorded)
+	}
+
+	entries, _ := h2.Entries()
+	if len(entries) != 1 {
+		t.Errorf("expected 1 entry, got %d", len(entries))
+	}
+}
+
+func TestHistoryStore_RotatesAndKeepsIDs(t *testing.T) {
+	h := newTestHistory(t, HistoryLimits{MaxBytes: 200, MaxFiles: 2})
+
+	statuses := []string{"pr", "building", "testing", "testok", "merging", "deploy", "complete"}
+	for i := 1; i < len(statuses); i++ {
+		_, err := h.Record(statusResults(map[string]string{"overall": statuses[i]}),
+			map[string]string{"overall": statuses[0]}, time.Now())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if _, err := os.Stat(h.path + ".1"); err != nil {
+		t.Errorf("expected rotated file to exist: %v", err)
+	}
+	if _, err := os.Stat(h.path + ".3"); err == nil {
+		t.Error("expected no more than 2 rotated files")
+	}
+
+	entries, _ := h.Entries()
+	if len(entries) == 0 || len(entries) >= len(statuses) {
+		t.Fatalf("expected some but not all entries to be kept, got %d", len(entries))
+	}
+	last := entries[len(entries)-1]
+	if last.ID != int64(len(statuses)-1) || last.To != "complete" {
+		t.Errorf("expected IDs to continue across rotation, got %+v", last)
+	}
+	for i := 1; i < len(entries); i++ {
+		if entries[i].ID != entries[i-1].ID+1 {
+			t.Errorf("expected consecutive IDs oldest first, got %d then %d", entries[i-1].ID, entries[i].ID)
+		}
+	}
+}
+
+func TestHistoryStore_SkipsCorruptLines(t *testing.T) {
+	h := newTestHistory(t, defaultHistoryLimits)
+	os.WriteFile(h.path, []byte("{\"id\":1,\"region\":\"au\",\"from\":\"pr\",\"to\":\"building\"}\n{\"id\":2,\"reg"), 0644)
+
+	entries, err := h.Entries()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected 1 entry, got %d", len(entries))
+	}
+}
+
+func TestHistoryStore_Query(t *testing.T) {
+	h := newTestHistory(t, defaultHistoryLimits)
+	base := time.Date(2026, 1, 30, 12, 0, 0, 0, time.UTC)
+
+	h.append([]transition{
+		{ID: 1, Region: "overall", From: "pr", To: "building", ChangedAt: base},
+		{ID: 2, Region: "au", From: "complete", To: "deploy", ChangedAt: base.Add(time.Hour)},
+		{ID: 3, Region: "overall", From: "building", To: "testing", ChangedAt: base.Add(2 * time.Hour)},
+		{ID: 4, Region: "overall", From: "testing", To: "testok", ChangedAt: base.Add(3 * time.Hour)},
+	})
+
+	tests := []struct {
+		name     string
+		filter   historyFilter
+		expected []int64
+	}{
+		{"all", historyFilter{}, []int64{1, 2, 3, 4}},
+		{"region", historyFilter{Region: "overall"}, []int64{1, 3, 4}},
+		{"since", historyFilter{Since: base.Add(90 * time.Minute)}, []int64{3, 4}},
+		{"until", historyFilter{Until: base.Add(time.Hour)}, []int64{1, 2}},
+		{"limit keeps most recent", historyFilter{Region: "overall", Limit: 2}, []int64{3, 4}},
+	}
+
+	for _, tt := range tests {
+		entries, err := h.Query(tt.filter)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		var ids []int64
+		for _, entry := range entries {
+			ids = append(ids, entry.ID)
+		}
+		if len(ids) != len(tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, ids)
+			continue
+		}
+		for i := range ids {
+			if ids[i] != tt.expected[i] {
+				t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, ids)
+				break
+			}
+		}
+	}
+}
+
+func TestStatusCache_UpdateAllRecordsHistory(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "statuses.json")
+	cache := NewStatusCacheWithPath(tmpFile)
+
+	cache.UpdateAll(statusResults(map[string]string{"overall": "testing"}))
+	cache.UpdateAll(map[string]statusResult{"overall": {region: "overall", err: errors.New("timeout")}})
+	cache.UpdateAll(statusResults(map[string]string{"overall": "testok"}))
+	cache.UpdateAll(statusResults(map[string]string{"overall": "testok"}))
+
+	entries, err := cache.History().Entries()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 transitions, got %+v", entries)
+	}
+	if entries[0].From != "" || entries[0].To != "testing" {
+		t.Errorf("expected first observation of testing, got %+v", entries[0])
+	}
+	if entries[1].From != "testing" || entries[1].To != "testok" {
+		t.Errorf("expected testing -> testok across the fetch error, got %+v", entries[1])
+	}
+	if filepath.Dir(cache.History().path) != filepath.Dir(tmpFile) {
+		t.Error("expected history to be stored next to the cache file")
+	}
+}
+
+func TestParseTimeFlag(t *testing.T) {
+	now := time.Date(2026, 1, 30, 12, 0, 0, 0, time.UTC)
+
+	got, err := parseTimeFlag("24h", now)
+	if err != nil || !got.Equal(now.Add(-24*time.Hour)) {
+		t.Errorf("duration: got %v (err %v)", got, err)
+	}
+
+	got, err = parseTimeFlag("2026-01-29T08:00:00Z", now)
+	if err != nil || !got.Equal(time.Date(2026, 1, 29, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("RFC 3339: got %v (err %v)", got, err)
+	}
+
+	if _, err := parseTimeFlag("2026-01-29", now); err != nil {
+		t.Errorf("date: unexpected error: %v", err)
+	}
+
+	got, err = parseTimeFlag("", now)
+	if err != nil || !got.IsZero() {
+		t.Errorf("empty: expected zero time, got %v (err %v)", got, err)
+	}
+
+	if _, err := parseTimeFlag("yesterday", now); err == nil {
+		t.Error("expected error for invalid time")
+	}
+}
+
+func TestPrintHistory(t *testing.T) {
+	var buf bytes.Buffer
+	printHistory(&buf, []transition{
+		{ID: 1, Region: "au", From: "testing", To: "testok", ChangedAt: time.Now()},
+	})
+
+	if !strings.Contains(buf.String(), "AU") || !strings.Contains(buf.String(), "testing -> testok") {
+		t.Errorf("unexpected output: %q", buf.String())
+	}
+}
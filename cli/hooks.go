@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HookConfig holds settings shared by all command hooks
+type HookConfig struct {
+	Timeout time.Duration `toml:"timeout"` // how long a hook may run before it's killed
+}
+
+var defaultHookConfig = HookConfig{Timeout: 30 * time.Second}
+
+// Hook execution limits
+const (
+	hookQueueSize      = 100      // transitions queued before new ones are dropped
+	hookMaxOutputBytes = 16 << 10 // output kept per run in the hook log
+	hookKillGrace      = 2 * time.Second
+)
+
+// hook is a command run on status transitions
+type hook struct {
+	name    string // "on-change" or "on.<status>", shown in the hook log
+	command string
+	status  string // only run on transitions to this status, or on every change if empty
+}
+
+// matches reports whether h should run for entry
+func (h hook) matches(entry transition) bool {
+	return h.status == "" || strings.EqualFold(h.status, entry.To)
+}
+
+// configHooks returns the hooks from the [on] config table, ordered by status
+func configHooks(stateHooks map[string]string) []hook {
+	statuses := make([]string, 0, len(stateHooks))
+	for status := range stateHooks {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+
+	hooks := make([]hook, 0, len(statuses))
+	for _, status := range statuses {
+		hooks = append(hooks, hook{name: "on." + status, command: stateHooks[status], status: status})
+	}
+	return hooks
+}
+
+// hookRun is the outcome of running a hook, as written to the hook log
+type hookRun struct {
+	Hook       string    `json:"hook"`
+	Command    string    `json:"command"`
+	Transition int64     `json:"transition"`
+	Region     string    `json:"region"`
+	ExitCode   int       `json:"exitCode"`
+	Error      string    `json:"error,omitempty"`
+	Output     string    `json:"output,omitempty"` // combined stdout and stderr, truncated
+	StartedAt  time.Time `json:"startedAt"`
+	Duration   float64   `json:"durationSeconds"`
+}
+
+// hookEnv returns the environment a hook runs with for entry
+func hookEnv(entry transition) []string {
+	return append(os.Environ(),
+		"DEPLOY_REGION="+entry.Region,
+		"DEPLOY_REGION_LABEL="+regionLabel(entry.Region),
+		"DEPLOY_OLD_STATUS="+entry.From,
+		"DEPLOY_NEW_STATUS="+entry.To,
+		"DEPLOY_CHANGED_AT="+entry.ChangedAt.Format(time.RFC3339),
+	)
+}
+
+// shellCommand returns a command running line with the platform's shell
+func shellCommand(ctx context.Context, line string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", line)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", line)
+}
+
+// limitedBuffer keeps the first max bytes written to it and discards the rest
+type limitedBuffer struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if room := b.max - b.buf.Len(); room < len(p) {
+		b.buf.Write(p[:max(room, 0)])
+		b.truncated = true
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.truncated {
+		return b.buf.String() + "\n[output truncated]"
+	}
+	return b.buf.String()
+}
+
+// runHook runs h for entry, killing it after timeout or once ctx is done. Its output is
+// captured rather than written to the terminal so it can't disturb the watch display.
+func runHook(ctx context.Context, h hook, entry transition, timeout time.Duration) hookRun {
+	hookCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	output := &limitedBuffer{max: hookMaxOutputBytes}
+	cmd := shellCommand(hookCtx, h.command)
+	cmd.Env = hookEnv(entry)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	// Don't wait forever for background processes the hook started to close its output
+	cmd.WaitDelay = hookKillGrace
+
+	run := hookRun{
+		Hook:       h.name,
+		Command:    h.command,
+		Transition: entry.ID,
+		Region:     entry.Region,
+		StartedAt:  time.Now(),
+	}
+	err := cmd.Run()
+	run.Duration = time.Since(run.StartedAt).Seconds()
+	run.Output = output.String()
+
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() != nil:
+		run.ExitCode = -1
+		run.Error = "killed at shutdown"
+	case hookCtx.Err() != nil:
+		run.ExitCode = -1
+		run.Error = fmt.Sprintf("timed out after %s", timeout)
+	case errors.As(err, &exitErr):
+		run.ExitCode = exitErr.ExitCode()
+		run.Error = err.Error()
+	case err != nil:
+		run.ExitCode = -1
+		run.Error = err.Error()
+	}
+	return run
+}
+
+// hookRunner runs hooks for queued transitions one at a time, so hooks such as sounds
+// don't overlap and a slow hook can't hold up fetching or the display
+type hookRunner struct {
+	hooks   []hook
+	timeout time.Duration
+	log     *jsonLinesLog
+	queue   chan transition
+}
+
+// newHookRunner creates a runner for hooks, logging runs to logPath
+func newHookRunner(hooks []hook, timeout time.Duration, logPath string) *hookRunner {
+	return &hookRunner{
+		hooks:   hooks,
+		timeout: timeout,
+		log:     newJSONLinesLog(logPath, currentConfig().History),
+		queue:   make(chan transition, hookQueueSize),
+	}
+}
+
+// enqueue queues transitions to run hooks for without blocking, except first observations
+func (r *hookRunner) enqueue(entries []transition) {
+	for _, entry := range entries {
+		if entry.From == "" {
+			continue
+		}
+		select {
+		case r.queue <- entry:
+		default:
+			r.drop(entry, "hook queue full")
+		}
+	}
+}
+
+// drop logs entry's hooks as not run, and why
+func (r *hookRunner) drop(entry transition, reason string) {
+	r.record(hookRun{
+		Transition: entry.ID,
+		Region:     entry.Region,
+		ExitCode:   -1,
+		Error:      reason + ", transition dropped",
+		StartedAt:  time.Now(),
+	})
+}
+
+// run runs hooks for queued transitions until ctx is done, then runs them for the
+// transitions still queued for up to shutdownGrace, logging any left after that as dropped
+func (r *hookRunner) run(ctx context.Context) {
+	drainCtx, cancel := graceContext(ctx, shutdownGrace)
+	defer cancel()
+
+	for {
+		select {
+		case entry := <-r.queue:
+			r.runHooks(drainCtx, entry)
+		case <-ctx.Done():
+			r.drain(drainCtx)
+			return
+		}
+	}
+}
+
+// drain runs hooks for the transitions left in the queue while ctx lasts
+func (r *hookRunner) drain(ctx context.Context) {
+	for {
+		select {
+		case entry := <-r.queue:
+			if ctx.Err() != nil {
+				r.drop(entry, "shutting down")
+				continue
+			}
+			r.runHooks(ctx, entry)
+		default:
+			return
+		}
+	}
+}
+
+// runHooks runs the hooks matching entry, one after another
+func (r *hookRunner) runHooks(ctx context.Context, entry transition) {
+	for _, h := range r.hooks {
+		if h.matches(entry) {
+			r.record(runHook(ctx, h, entry, r.timeout))
+		}
+	}
+}
+
+// record writes a hook run to the log, reporting failures to write it on stderr
+func (r *hookRunner) record(run hookRun) {
+	if err := r.log.append(run); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing hook log: %v\n", err)
+	}
+}
+
+// startHooks runs the config file's hooks, and the --on-change command that belongs to this
+// process alone, in the background, returning a function that waits for the hooks queued
+// when ctx is done.
+func startHooks(ctx context.Context, cache *StatusCache, lease *Lease, onChange string) func() {
+	cfg := currentConfig()
+	logPath := filepath.Join(filepath.Dir(cache.filePath), "hooks.jsonl")
+	var wg sync.WaitGroup
+
+	if hooks := configHooks(cfg.On); len(hooks) > 0 {
+		r := newHookRunner(hooks, cfg.Hooks.Timeout, logPath)
+		forwarded := followTransitions(ctx, cache, lease.Held, cache.Silences().filterTransitions(r.enqueue))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.run(forwarded)
+		}()
+	}
+
+	// --on-change runs for every transition, not just while this process holds lease
+	if onChange != "" {
+		r := newHookRunner([]hook{{name: "on-change", command: onChange}}, cfg.Hooks.Timeout, logPath)
+		forwarded := followTransitions(ctx, cache, nil, cache.Silences().filterTransitions(r.enqueue))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.run(forwarded)
+		}()
+	}
+	return wg.Wait
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// skipWithoutShell skips tests whose hook commands need a POSIX shell
+func skipWithoutShell(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("hook commands in these tests use sh")
+	}
+}
+
+func TestRunHook_SetsEnvironment(t *testing.T) {
+	skipWithoutShell(t)
+
+	entry := transition{ID: 3, Region: "au", From: "testing", To: "testfail", ChangedAt: time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)}
+	run := runHook(context.Background(), hook{name: "on-change", command: `echo "$DEPLOY_REGION $DEPLOY_REGION_LABEL $DEPLOY_OLD_STATUS $DEPLOY_NEW_STATUS $DEPLOY_CHANGED_AT"`}, entry, time.Second)
+
+	if run.Error != "" || run.ExitCode != 0 {
+		t.Fatalf("unexpected failure: %+v", run)
+	}
+	if run.Output != "au AU testing testfail 2026-10-16T09:30:00Z\n" {
+		t.Errorf("unexpected output %q", run.Output)
+	}
+	if run.Hook != "on-change" || run.Transition != 3 || run.Region != "au" {
+		t.Errorf("unexpected run %+v", run)
+	}
+}
+
+func TestRunHook_CapturesFailure(t *testing.T) {
+	skipWithoutShell(t)
+
+	run := runHook(context.Background(), hook{command: "echo oops >&2; exit 3"}, transition{Region: "au"}, time.Second)
+
+	if run.ExitCode != 3 || run.Error == "" {
+		t.Errorf("expected exit code 3 with an error, got %+v", run)
+	}
+	if run.Output != "oops\n" {
+		t.Errorf("expected stderr to be captured, got %q", run.Output)
+	}
+}
+
+func TestRunHook_Timeout(t *testing.T) {
+	skipWithoutShell(t)
+
+	start := time.Now()
+	run := runHook(context.Background(), hook{command: "echo started; sleep 10"}, transition{Region: "au"}, 100*time.Millisecond)
+
+	if elapsed := time.Since(start); elapsed > hookKillGrace+time.Second {
+		t.Errorf("expected hook to be killed promptly, took %v", elapsed)
+	}
+	if !strings.Contains(run.Error, "timed out") || run.ExitCode != -1 {
+		t.Errorf("expected a timeout, got %+v", run)
+	}
+	if run.Output != "started\n" {
+		t.Errorf("expected output before the timeout to be kept, got %q", run.Output)
+	}
+}
+
+func TestRunHook_KilledAtShutdown(t *testing.T) {
+	skipWithoutShell(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	run := runHook(ctx, hook{command: "sleep 10"}, transition{Region: "au"}, time.Minute)
+
+	if run.Error != "killed at shutdown" || run.ExitCode != -1 {
+		t.Errorf("expected the hook to be killed at shutdown, got %+v", run)
+	}
+}
+
+func TestRunHook_TruncatesOutput(t *testing.T) {
+	skipWithoutShell(t)
+
+	run := runHook(context.Background(), hook{command: "head -c 100000 /dev/zero | tr '\\0' x"}, transition{Region: "au"}, 5*time.Second)
+
+	if !strings.HasSuffix(run.Output, "[output truncated]") || len(run.Output) > hookMaxOutputBytes+100 {
+		t.Errorf("expected output to be truncated, got %d bytes", len(run.Output))
+	}
+}
+
+func TestRunHook_MissingCommand(t *testing.T) {
+	skipWithoutShell(t)
+
+	run := runHook(context.Background(), hook{command: "definitely-not-a-command-xyz"}, transition{Region: "au"}, time.Second)
+
+	if run.ExitCode == 0 || run.Error == "" {
+		t.Errorf("expected a failure, got %+v", run)
+	}
+}
+
+func TestConfigHooks_MatchStatus(t *testing.T) {
+	hooks := configHooks(map[string]string{"testfail": "a", "complete": "b"})
+	if len(hooks) != 2 || hooks[0].name != "on.complete" || hooks[1].name != "on.testfail" {
+		t.Fatalf("expected hooks ordered by status, got %+v", hooks)
+	}
+	if !hooks[1].matches(transition{To: "TestFail"}) || hooks[1].matches(transition{To: "testok"}) {
+		t.Error("expected on.testfail to match only testfail transitions")
+	}
+	if !(hook{}).matches(transition{To: "anything"}) {
+		t.Error("expected a hook without a status to match every change")
+	}
+}
+
+func TestHookRunner_RunsMatchingHooksAndLogs(t *testing.T) {
+	skipWithoutShell(t)
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out.txt")
+	t.Setenv("HOOK_OUT", out)
+
+	logPath := filepath.Join(dir, "hooks.jsonl")
+	r := newHookRunner([]hook{
+		{name: "on-change", command: `echo "change $DEPLOY_NEW_STATUS" >> "$HOOK_OUT"`},
+		{name: "on.testfail", command: `echo "failed $DEPLOY_REGION" >> "$HOOK_OUT"; exit 1`, status: "testfail"},
+	}, time.Second, logPath)
+
+	cache := NewStatusCacheWithPath(filepath.Join(dir, "statuses.json"))
+	cache.OnTransitions(r.enqueue)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.run(ctx)
+
+	cache.UpdateAll(map[string]statusResult{"au": {region: "au", status: "testing"}}) // First observation, no hooks
+	cache.UpdateAll(map[string]statusResult{"au": {region: "au", status: "testfail"}})
+	cache.UpdateAll(map[string]statusResult{"au": {region: "au", status: "testing"}})
+
+	expected := "change testfail\nfailed au\nchange testing\n"
+	var data []byte
+	for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if data, _ = os.ReadFile(out); string(data) == expected {
+			break
+		}
+	}
+	if string(data) != expected {
+		t.Fatalf("expected hooks to run in order:\n%s\ngot:\n%s", expected, data)
+	}
+
+	// The failing hook is logged and doesn't stop later hooks from running
+	var logData []byte
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if logData, _ = os.ReadFile(logPath); strings.Count(string(logData), "\n") == 3 {
+			break
+		}
+	}
+	if !strings.Contains(string(logData), `"hook":"on.testfail"`) || !strings.Contains(string(logData), `"exitCode":1`) {
+		t.Errorf("expected failed hook in the log, got:\n%s", logData)
+	}
+}
+
+func TestHookRunner_DrainsQueueOnShutdown(t *testing.T) {
+	skipWithoutShell(t)
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out.txt")
+	t.Setenv("HOOK_OUT", out)
+
+	r := newHookRunner([]hook{{name: "on-change", command: `echo "$DEPLOY_NEW_STATUS" >> "$HOOK_OUT"`}},
+		time.Second, filepath.Join(dir, "hooks.jsonl"))
+
+	// Recorded just before shutdown, so still queued when ctx is done
+	r.enqueue([]transition{
+		{ID: 1, Region: "au", From: "testing", To: "testok"},
+		{ID: 2, Region: "au", From: "testok", To: "merging"},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r.run(ctx)
+
+	if data, _ := os.ReadFile(out); string(data) != "testok\nmerging\n" {
+		t.Errorf("expected hooks to run for both queued transitions, got %q", data)
+	}
+}
+
+func TestStartHooks_OnChangeFollowsOtherProcesses(t *testing.T) {
+	skipWithoutShell(t)
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out.txt")
+	t.Setenv("HOOK_OUT", out)
+
+	cache := NewStatusCacheWithPath(filepath.Join(dir, "statuses.json"))
+	ctx, cancel := context.WithCancel(context.Background())
+	lease := NewLease(filepath.Join(dir, "fetcher.lease"), leaseTTL) // Held by the other process
+	NewLease(lease.path, leaseTTL).TryAcquire()
+	wait := startHooks(ctx, cache, lease, `echo "$DEPLOY_REGION $DEPLOY_OLD_STATUS $DEPLOY_NEW_STATUS" >> "$HOOK_OUT"`)
+	defer func() {
+		cancel()
+		wait()
+	}()
+
+	// Another process holding the fetcher lease records the transitions
+	other := NewStatusCacheWithPath(cache.filePath)
+	other.UpdateAll(map[string]statusResult{"us": {region: "us", status: "merging"}})
+	other.UpdateAll(map[string]statusResult{"us": {region: "us", status: "deploy"}})
+
+	var data []byte
+	for deadline := time.Now().Add(eventsPollInterval + 3*time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if data, _ = os.ReadFile(out); len(data) > 0 {
+			break
+		}
+	}
+	if string(data) != "us merging deploy\n" {
+		t.Errorf("expected --on-change to run for the other process's transition, got %q", data)
+	}
+}
+
+func TestStartHooks_ConfigHooksFollowOtherProcessesWhileHoldingLease(t *testing.T) {
+	skipWithoutShell(t)
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out.txt")
+	t.Setenv("HOOK_OUT", out)
+	withConfig(t, func(cfg *Config) {
+		cfg.On = map[string]string{"complete": `echo "$DEPLOY_REGION $DEPLOY_NEW_STATUS" >> "$HOOK_OUT"`}
+	})
+
+	cache := NewStatusCacheWithPath(filepath.Join(dir, "statuses.json"))
+	cache.UpdateAll(map[string]statusResult{"au": {region: "au", status: "testing"}})
+	held := NewLease(filepath.Join(dir, "fetcher.lease"), leaseTTL)
+	held.TryAcquire()
+	notHeld := NewLease(held.path, leaseTTL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	waitHeld := startHooks(ctx, cache, held, "")
+	waitNotHeld := startHooks(ctx, NewStatusCacheWithPath(cache.filePath), notHeld, "")
+
+	// A one-shot check sees the change first, so the fetcher's next fetch records nothing
+	other := NewStatusCacheWithPath(cache.filePath)
+	other.UpdateAll(map[string]statusResult{"au": {region: "au", status: "complete"}})
+	cache.Reload()
+	cache.UpdateAll(map[string]statusResult{"au": {region: "au", status: "complete"}})
+
+	var data []byte
+	for deadline := time.Now().Add(eventsPollInterval + 3*time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if data, _ = os.ReadFile(out); len(data) > 0 {
+			break
+		}
+	}
+	// Give the process without the lease a chance to run it too
+	time.Sleep(eventsPollInterval)
+	cancel()
+	waitHeld()
+	waitNotHeld()
+
+	if data, _ = os.ReadFile(out); string(data) != "au complete\n" {
+		t.Errorf("expected on.complete to run once, in the lease holder, got %q", data)
+	}
+}
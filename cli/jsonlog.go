@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// logFiles returns the paths of a rotated log, oldest first: path.maxFiles down to path.1,
+// then path itself
+func logFiles(path string, maxFiles int) []string {
+	paths := make([]string, 0, maxFiles+1)
+	for i := maxFiles; i >= 1; i-- {
+		paths = append(paths, fmt.Sprintf("%s.%d", path, i))
+	}
+	return append(paths, path)
+}
+
+// rotateLog shifts each of path's log files up by one number, dropping the oldest. Callers
+// must hold the log's file lock.
+func rotateLog(path string, maxFiles int) error {
+	files := logFiles(path, maxFiles)
+	if err := os.Remove(files[0]); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for i := 1; i < len(files); i++ {
+		if err := os.Rename(files[i], files[i-1]); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonLinesLog appends records to a JSON lines file shared between processes, rotated like
+// the history
+type jsonLinesLog struct {
+	mu     sync.Mutex
+	path   string
+	limits HistoryLimits
+}
+
+// newJSONLinesLog creates a log appending to path, rotated within limits
+func newJSONLinesLog(path string, limits HistoryLimits) *jsonLinesLog {
+	return &jsonLinesLog{path: path, limits: limits}
+}
+
+// append writes v to the log as a single line, rotating the log once it grows too large
+func (l *jsonLinesLog) append(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return withFileLock(l.path+".lock", func() error {
+		f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(append(data, '\n'))
+		info, statErr := f.Stat()
+		f.Close()
+		if err != nil {
+			return err
+		}
+		if statErr == nil && l.limits.MaxBytes > 0 && info.Size() > l.limits.MaxBytes {
+			return rotateLog(l.path, l.limits.MaxFiles)
+		}
+		return nil
+	})
+}
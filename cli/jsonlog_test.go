@@ -0,0 +1,34 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJSONLinesLog_Rotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.jsonl")
+	log := newJSONLinesLog(path, HistoryLimits{MaxBytes: 10000, MaxFiles: 2})
+
+	line := strings.Repeat("x", 1000)
+	for written := 0; written <= 2*10000; written += len(line) + 3 {
+		if err := log.append(line); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	for _, rotated := range []string{path + ".1", path + ".2"} {
+		if _, err := os.Stat(rotated); err != nil {
+			t.Errorf("expected log to be rotated to %s: %v", rotated, err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); err == nil {
+		t.Error("expected only max_files rotated files to be kept")
+	}
+	log.append("after")
+	data, _ := os.ReadFile(path)
+	if string(data) != "\"after\"\n" {
+		t.Errorf("expected a fresh log after rotation, got %d bytes", len(data))
+	}
+}
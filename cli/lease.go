@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Lease timing for electing a single fetcher among watch processes
+const (
+	leaseTTL           = 60 * time.Second // how long a lease lasts without renewal
+	leaseCheckInterval = 10 * time.Second // how often processes renew or try to take the lease
+)
+
+// leaseRecord is the lease state stored on disk
+type leaseRecord struct {
+	Holder    string    `json:"holder"`
+	PID       int       `json:"pid"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Lease elects one process at a time as the fetcher using a file in the cache directory.
+// The holder renews the lease while it's alive; once it stops renewing, the lease expires
+// and another process can take it over.
+type Lease struct {
+	path string
+	id   string
+	ttl  time.Duration
+	now  func() time.Time
+}
+
+// NewLease creates a Lease stored at path, identified by a unique holder ID for this process
+func NewLease(path string, ttl time.Duration) *Lease {
+	hostname, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+
+	return &Lease{
+		path: path,
+		id:   fmt.Sprintf("%s:%d:%s", hostname, os.Getpid(), hex.EncodeToString(suffix)),
+		ttl:  ttl,
+		now:  time.Now,
+	}
+}
+
+// read returns the stored lease, or a zero record if there isn't a valid one
+func (l *Lease) read() (leaseRecord, error) {
+	var record leaseRecord
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return record, nil
+		}
+		return record, err
+	}
+	if err := json.Unmarshal(data, &record); err != nil {
+		return leaseRecord{}, nil // Corrupt lease, treat as free
+	}
+	return record, nil
+}
+
+// TryAcquire takes the lease if it's free or expired, or renews it if this process
+// already holds it. It reports whether this process is the holder afterwards.
+func (l *Lease) TryAcquire() (bool, error) {
+	held := false
+	err := withFileLock(l.path+".lock", func() error {
+		record, err := l.read()
+		if err != nil {
+			return err
+		}
+
+		now := l.now()
+		if record.Holder != "" && record.Holder != l.id && now.Before(record.ExpiresAt) {
+			return nil
+		}
+
+		data, err := json.Marshal(leaseRecord{Holder: l.id, PID: os.Getpid(), ExpiresAt: now.Add(l.ttl)})
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(l.path, data, 0644); err != nil {
+			return err
+		}
+		held = true
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire lease: %w", err)
+	}
+	return held, nil
+}
+
+// Release gives up the lease if this process holds it, so another can take over immediately
+func (l *Lease) Release() error {
+	return withFileLock(l.path+".lock", func() error {
+		record, err := l.read()
+		if err != nil || record.Holder != l.id {
+			return err
+		}
+		if err := os.Remove(l.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to release lease: %w", err)
+		}
+		return nil
+	})
+}
+
+// Held reports whether this process holds the lease. Without a working lease every process
+// fetches, so it's then treated as held.
+func (l *Lease) Held() bool {
+	held := true
+	withFileLock(l.path+".lock", func() error {
+		record, err := l.read()
+		if err == nil {
+			held = record.Holder == l.id
+		}
+		return nil
+	})
+	return held
+}
+
+// fetcher runs the write side of watch mode. Only the process holding the lease fetches;
+// the others rely on its writes, which their display loops pick up with Reload.
+type fetcher struct {
+	cache    *StatusCache
+	lease    *Lease
+	fetch    func(context.Context, *StatusCache)
+	schedule *scheduler
+	fetched  bool // whether the last tick fetched
+}
+
+// newFetcher creates a fetcher sharing the lease in the cache's directory
+func newFetcher(cache *StatusCache) *fetcher {
+	return &fetcher{
+		cache:    cache,
+		lease:    NewLease(filepath.Join(filepath.Dir(cache.filePath), "fetcher.lease"), leaseTTL),
+		fetch:    fetchAllStatuses,
+		schedule: newScheduler(currentConfig().Poll),
+	}
+}
+
+// tick renews or tries to take the lease, fetches if this process holds it and a fetch
+// is due, and returns how long to wait before the next tick
+func (f *fetcher) tick(ctx context.Context) time.Duration {
+	held, err := f.lease.TryAcquire()
+	if err != nil {
+		// Without a working lease, fetching from every process beats not fetching at all
+		held = true
+	}
+
+	f.fetched = false
+	if !held {
+		f.schedule.fetchNow() // Fetch as soon as this process takes over
+		return leaseCheckInterval
+	}
+
+	if f.schedule.due() {
+		f.fetch(ctx, f.cache)
+		f.schedule.fetched(f.cache.GetAll())
+		f.fetched = true
+	}
+	return min(leaseCheckInterval, f.schedule.until())
+}
+
+// fetchLoop is what drives a fetcher in the background besides its schedule
+type fetchLoop struct {
+	refresh   <-chan struct{}            // a receive fetches straight away
+	reload    <-chan os.Signal           // a receive calls onReload, then fetches straight away
+	onReload  func()                     // re-applies the config, between fetches so none sees half of it
+	afterTick func(forced, fetched bool) // called after each tick, forced after a refresh or reload
+}
+
+// run ticks until ctx is done, starting wait after a tick the caller already made. A fetch in
+// progress when ctx is done is cancelled and run waits for its writes to finish, then
+// releases the lease so another process can take over without waiting for it to expire.
+func (f *fetcher) run(ctx context.Context, wait time.Duration, loop fetchLoop) {
+	defer f.lease.Release()
+
+	forced := false
+	for {
+		if loop.afterTick != nil {
+			loop.afterTick(forced, f.fetched)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+			forced = false
+		case <-loop.refresh:
+			forced = true
+		case <-loop.reload:
+			if loop.onReload != nil {
+				loop.onReload()
+			}
+			forced = true
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+		timer.Stop()
+
+		if forced {
+			f.schedule.fetchNow()
+		}
+		wait = f.tick(ctx)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced clock for tests
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) Now() time.Time          { return c.t }
+func (c *fakeClock) Advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestLease(path string, clock *fakeClock) *Lease {
+	lease := NewLease(path, time.Minute)
+	lease.now = clock.Now
+	return lease
+}
+
+func TestLease_OnlyOneHolder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fetcher.lease")
+	clock := &fakeClock{t: time.Now()}
+	a := newTestLease(path, clock)
+	b := newTestLease(path, clock)
+
+	if held, err := a.TryAcquire(); !held || err != nil {
+		t.Fatalf("expected first process to acquire lease, got %v (err %v)", held, err)
+	}
+	if held, _ := b.TryAcquire(); held {
+		t.Error("expected second process not to acquire a held lease")
+	}
+
+	// Renewing keeps the lease past the original expiry
+	clock.Advance(50 * time.Second)
+	if held, _ := a.TryAcquire(); !held {
+		t.Error("expected holder to renew lease")
+	}
+	clock.Advance(50 * time.Second)
+	if held, _ := b.TryAcquire(); held {
+		t.Error("expected renewed lease to still be held")
+	}
+}
+
+func TestLease_TakenOverAfterExpiry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fetcher.lease")
+	clock := &fakeClock{t: time.Now()}
+	a := newTestLease(path, clock)
+	b := newTestLease(path, clock)
+
+	a.TryAcquire()
+
+	// The holder dies and stops renewing
+	clock.Advance(61 * time.Second)
+	if held, _ := b.TryAcquire(); !held {
+		t.Fatal("expected expired lease to be taken over")
+	}
+	if held, _ := a.TryAcquire(); held {
+		t.Error("expected previous holder to have lost the lease")
+	}
+}
+
+func TestLease_Release(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fetcher.lease")
+	clock := &fakeClock{t: time.Now()}
+	a := newTestLease(path, clock)
+	b := newTestLease(path, clock)
+
+	a.TryAcquire()
+
+	// Releasing a lease held by someone else does nothing
+	b.Release()
+	if held, _ := b.TryAcquire(); held {
+		t.Error("expected lease to still be held after release by non-holder")
+	}
+
+	a.Release()
+	if held, _ := b.TryAcquire(); !held {
+		t.Error("expected released lease to be acquired immediately")
+	}
+}
+
+func TestFetcher_OnlyHolderFetches(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{t: time.Now()}
+	cache := NewStatusCacheWithPath(filepath.Join(dir, "statuses.json"))
+
+	fetches := map[string]int{}
+	newTestFetcher := func(name string) *fetcher {
+		f := newFetcher(cache)
+		f.lease.now = clock.Now
+		f.schedule.now = clock.Now
+		f.fetch = func(_ context.Context, c *StatusCache) {
+			fetches[name]++
+			c.UpdateAll(map[string]statusResult{"overall": {region: "overall", status: "testing"}})
+		}
+		return f
+	}
+
+	a := newTestFetcher("a")
+	b := newTestFetcher("b")
+
+	a.tick(context.Background())
+	if wait := b.tick(context.Background()); wait != leaseCheckInterval {
+		t.Errorf("expected follower to wait %v, got %v", leaseCheckInterval, wait)
+	}
+	if fetches["a"] != 1 || fetches["b"] != 0 {
+		t.Fatalf("expected only the holder to fetch, got %v", fetches)
+	}
+
+	// A tick before the next fetch is due renews the lease without fetching
+	clock.Advance(leaseCheckInterval)
+	a.tick(context.Background())
+	if fetches["a"] != 1 {
+		t.Errorf("expected no fetch before interval elapsed, got %d", fetches["a"])
+	}
+
+	clock.Advance(currentConfig().Poll.Active)
+	a.tick(context.Background())
+	if fetches["a"] != 2 {
+		t.Errorf("expected a fetch once the interval elapsed, got %d", fetches["a"])
+	}
+
+	// The holder stops ticking, so the follower takes over and fetches straight away
+	clock.Advance(leaseTTL + time.Second)
+	b.tick(context.Background())
+	if fetches["b"] != 1 {
+		t.Errorf("expected follower to fetch after taking over, got %d", fetches["b"])
+	}
+}
+
+func TestFetcher_RunRefreshReloadAndStop(t *testing.T) {
+	cache := NewStatusCacheWithPath(filepath.Join(t.TempDir(), "statuses.json"))
+	f := newFetcher(cache)
+	fetched := make(chan struct{}, 1)
+	f.fetch = func(_ context.Context, c *StatusCache) {
+		c.UpdateAll(map[string]statusResult{"overall": {region: "overall", status: "testing"}})
+		fetched <- struct{}{}
+	}
+
+	refresh := make(chan struct{})
+	reload := make(chan os.Signal)
+	reloaded := false
+	var forced, fetchedOnTick []bool
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		f.run(ctx, time.Hour, fetchLoop{
+			refresh:  refresh,
+			reload:   reload,
+			onReload: func() { reloaded = true },
+			afterTick: func(wasForced, didFetch bool) {
+				forced = append(forced, wasForced)
+				fetchedOnTick = append(fetchedOnTick, didFetch)
+			},
+		})
+	}()
+
+	// Nothing is fetched until the wait is over or a fetch is asked for
+	refresh <- struct{}{}
+	<-fetched
+	reload <- syscall.SIGHUP
+	<-fetched
+
+	cancel()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected run to stop once cancelled")
+	}
+	if !reloaded {
+		t.Error("expected the reload to be applied")
+	}
+	if len(forced) != 3 || forced[0] || !forced[1] || !forced[2] {
+		t.Errorf("expected afterTick once before waiting and after each forced fetch, got %v", forced)
+	}
+	if len(fetchedOnTick) != 3 || fetchedOnTick[0] || !fetchedOnTick[1] || !fetchedOnTick[2] {
+		t.Errorf("expected afterTick to report the forced fetches, got %v", fetchedOnTick)
+	}
+	if _, err := os.Stat(f.lease.path); !os.IsNotExist(err) {
+		t.Errorf("expected the lease to be released on the way out, got %v", err)
+	}
+}
+
+func TestLease_Held(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fetcher.lease")
+	a := NewLease(path, leaseTTL)
+	b := NewLease(path, leaseTTL)
+
+	if a.Held() {
+		t.Error("expected a free lease not to be held")
+	}
+	a.TryAcquire()
+	if !a.Held() || b.Held() {
+		t.Errorf("expected only the holder to hold the lease, got %v and %v", a.Held(), b.Held())
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -11,19 +12,20 @@ import (
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/fatih/color"
 )
 
-var statusURLs = map[string]string{
-	"overall": "https://content.fcsuite.com/deploy/deploy",
-	"au":      "https://content.fcsuite.com/deploy/deploy-au",
-	"ca":      "https://content.fcsuite.com/deploy/deploy-ca",
-	"or":      "https://content.fcsuite.com/deploy/deploy-or",
-	"us":      "https://content.fcsuite.com/deploy/deploy-us",
+func init() {
+	applyConfig(DefaultConfig())
 }
 
-var regions = []string{"overall", "au", "ca", "or", "us"}
+// regionLabel returns the display label for a region in the active config, falling back to
+// its upper-cased ID
+func regionLabel(region string) string {
+	return currentConfig().Label(region)
+}
 
 // Status colors
 var (
@@ -32,26 +34,43 @@ var (
 	blueStatuses  = []string{"pr"}
 )
 
+// Status color classes returned by classifyStatus
+const (
+	classRed   = "red"
+	classGreen = "green"
+	classBlue  = "blue"
+	classWhite = "white"
+)
+
 type statusResult struct {
-	region string
-	status string
-	err    error
+	region   string
+	status   string
+	err      error
+	attempts int // number of fetch attempts made, 0 if unknown
 }
 
 // cachedStatus represents a status entry stored on disk
 type cachedStatus struct {
 	Status    string    `json:"status"`
 	Error     string    `json:"error,omitempty"`
+	Attempts  int       `json:"attempts,omitempty"`
 	UpdatedAt time.Time `json:"updatedAt"`
 }
 
 // StatusCache stores deployment statuses in memory and persists to disk
 type StatusCache struct {
 	mu            sync.RWMutex
+	saveMu        sync.Mutex // held from changing the statuses until they're on disk
 	statuses      map[string]cachedStatus
 	filePath      string
 	lastReadAt    time.Time
 	lastWrittenAt time.Time
+	lastFetchedAt time.Time
+	history       *HistoryStore
+	subscriptions *SubscriptionStore
+	silences      *SilenceStore
+	listeners     []func([]transition)
+	updated       []func(map[string]statusResult)
 }
 
 // getCacheDir returns the cache directory path using OS-appropriate location
@@ -75,8 +94,11 @@ func NewStatusCache() (*StatusCache, error) {
 	}
 
 	cache := &StatusCache{
-		statuses: make(map[string]cachedStatus),
-		filePath: filepath.Join(cacheDir, "statuses.json"),
+		statuses:      make(map[string]cachedStatus),
+		filePath:      filepath.Join(cacheDir, "statuses.json"),
+		history:       NewHistoryStore(filepath.Join(cacheDir, "history.jsonl"), currentConfig().History),
+		subscriptions: NewSubscriptionStore(filepath.Join(cacheDir, "subscriptions.json")),
+		silences:      NewSilenceStore(filepath.Join(cacheDir, "silences.json")),
 	}
 
 	cache.load()
@@ -86,15 +108,22 @@ func NewStatusCache() (*StatusCache, error) {
 // NewStatusCacheWithPath creates a StatusCache with a custom file path (for testing)
 func NewStatusCacheWithPath(filePath string) *StatusCache {
 	cache := &StatusCache{
-		statuses: make(map[string]cachedStatus),
-		filePath: filePath,
+		statuses:      make(map[string]cachedStatus),
+		filePath:      filePath,
+		history:       NewHistoryStore(filepath.Join(filepath.Dir(filePath), "history.jsonl"), currentConfig().History),
+		subscriptions: NewSubscriptionStore(filepath.Join(filepath.Dir(filePath), "subscriptions.json")),
+		silences:      NewSilenceStore(filepath.Join(filepath.Dir(filePath), "silences.json")),
 	}
 	cache.load()
 	return cache
 }
 
-// load reads the cache from disk
+// load reads the cache from disk. It waits for this process's changes to be saved first, so
+// the file never predates them.
 func (c *StatusCache) load() {
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+
 	data, err := os.ReadFile(c.filePath)
 	if err != nil {
 		return // File doesn't exist or can't be read, start with empty cache
@@ -116,7 +145,9 @@ func (c *StatusCache) Reload() {
 	c.load()
 }
 
-// save writes the cache to disk
+// save writes the statuses to disk atomically while holding the cache's file lock. Callers
+// must hold c.saveMu from changing the statuses until save returns, so changes are saved in
+// the order they were made.
 func (c *StatusCache) save() error {
 	c.mu.RLock()
 	data, err := json.MarshalIndent(c.statuses, "", "  ")
@@ -126,19 +157,26 @@ func (c *StatusCache) save() error {
 		return fmt.Errorf("failed to marshal cache: %w", err)
 	}
 
-	if err := os.WriteFile(c.filePath, data, 0644); err != nil {
+	err = withFileLock(c.filePath+".lock", func() error {
+		return writeFileAtomic(c.filePath, data, 0644)
+	})
+	if err != nil {
 		return fmt.Errorf("failed to write cache: %w", err)
 	}
 
 	c.mu.Lock()
 	c.lastWrittenAt = time.Now()
 	c.mu.Unlock()
+	metrics.observeCacheWrite()
 
 	return nil
 }
 
 // Update stores a status result in the cache and saves to disk
 func (c *StatusCache) Update(result statusResult) error {
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+
 	c.mu.Lock()
 	cached := cachedStatus{
 		Status:    result.status,
@@ -146,55 +184,123 @@ func (c *StatusCache) Update(result statusResult) error {
 	}
 	if result.err != nil {
 		cached.Error = result.err.Error()
+		cached.Attempts = result.attempts
 	}
 	c.statuses[result.region] = cached
 	c.mu.Unlock()
-
 	return c.save()
 }
 
-// UpdateAll stores multiple status results and saves only if there are changes
+// UpdateAll stores multiple status results and saves only if there are changes.
+// Status transitions are appended to the history log.
 func (c *StatusCache) UpdateAll(results map[string]statusResult) error {
+	c.saveMu.Lock()
 	c.mu.Lock()
+	c.lastFetchedAt = time.Now()
 
-	// Check if any status or error has changed
-	hasChanges := false
+	// Find the regions whose status or error has changed
+	changed := make(map[string]cachedStatus)
 	for region, result := range results {
-		existing, ok := c.statuses[region]
-		if !ok {
-			hasChanges = true
-			break
-		}
-		newError := ""
+		cached := cachedStatus{Status: result.status}
 		if result.err != nil {
-			newError = result.err.Error()
+			cached.Error = result.err.Error()
+			cached.Attempts = result.attempts
 		}
-		if existing.Status != result.status || existing.Error != newError {
-			hasChanges = true
-			break
+		existing, ok := c.statuses[region]
+		if ok && existing.Status == cached.Status && existing.Error == cached.Error && existing.Attempts == cached.Attempts {
+			continue
 		}
+		changed[region] = cached
 	}
 
-	if !hasChanges {
+	if len(changed) == 0 {
 		c.mu.Unlock()
+		c.saveMu.Unlock()
+		c.notifyUpdated()
 		return nil
 	}
 
-	// Apply updates
-	now := time.Now()
-	for region, result := range results {
-		cached := cachedStatus{
-			Status:    result.status,
-			UpdatedAt: now,
-		}
-		if result.err != nil {
-			cached.Error = result.err.Error()
+	// Remember the last good statuses so the history has a starting point
+	previous := make(map[string]string, len(c.statuses))
+	for region, cached := range c.statuses {
+		if cached.Error == "" && cached.Status != "" {
+			previous[region] = cached.Status
 		}
+	}
+
+	// Apply updates, timestamping only the regions that changed
+	now := time.Now()
+	for region, cached := range changed {
+		cached.UpdatedAt = now
 		c.statuses[region] = cached
 	}
 	c.mu.Unlock()
+	if err := c.save(); err != nil {
+		c.saveMu.Unlock()
+		return err
+	}
+	// Recorded in the same order as saved, so each transition starts where the last ended
+	recorded, err := c.history.Record(results, previous, now)
+	c.saveMu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to record history: %w", err)
+	}
 
-	return c.save()
+	if len(recorded) > 0 {
+		c.mu.RLock()
+		listeners := c.listeners
+		c.mu.RUnlock()
+		for _, fn := range listeners {
+			fn(recorded)
+		}
+	}
+	c.notifyUpdated()
+	return nil
+}
+
+// notifyUpdated calls the OnUpdate functions with the cached statuses
+func (c *StatusCache) notifyUpdated() {
+	c.mu.RLock()
+	updated := c.updated
+	c.mu.RUnlock()
+	if len(updated) == 0 {
+		return
+	}
+	statuses := c.GetAll()
+	for _, fn := range updated {
+		fn(statuses)
+	}
+}
+
+// OnTransitions registers fn to be called with the transitions recorded by each UpdateAll
+// in this process. Transitions written by other processes are only visible in the history.
+func (c *StatusCache) OnTransitions(fn func([]transition)) {
+	c.mu.Lock()
+	c.listeners = append(c.listeners, fn)
+	c.mu.Unlock()
+}
+
+// OnUpdate registers fn to be called with the cached statuses after each UpdateAll in this
+// process, whether or not anything changed
+func (c *StatusCache) OnUpdate(fn func(map[string]statusResult)) {
+	c.mu.Lock()
+	c.updated = append(c.updated, fn)
+	c.mu.Unlock()
+}
+
+// History returns the transition log stored alongside the cache
+func (c *StatusCache) History() *HistoryStore {
+	return c.history
+}
+
+// Subscriptions returns the Slack channel subscriptions stored alongside the cache
+func (c *StatusCache) Subscriptions() *SubscriptionStore {
+	return c.subscriptions
+}
+
+// Silences returns the silences and acknowledgements stored alongside the cache
+func (c *StatusCache) Silences() *SilenceStore {
+	return c.silences
 }
 
 // Get retrieves a status result from the cache
@@ -213,6 +319,7 @@ func (c *StatusCache) Get(region string) (statusResult, bool) {
 	}
 	if cached.Error != "" {
 		result.err = fmt.Errorf("%s", cached.Error)
+		result.attempts = cached.Attempts
 	}
 	return result, true
 }
@@ -230,6 +337,7 @@ func (c *StatusCache) GetAll() map[string]statusResult {
 		}
 		if cached.Error != "" {
 			result.err = fmt.Errorf("%s", cached.Error)
+			result.attempts = cached.Attempts
 		}
 		results[region] = result
 	}
@@ -262,62 +370,200 @@ func (c *StatusCache) GetLastWrittenAt() time.Time {
 	return c.lastWrittenAt
 }
 
+// GetFileModTime returns when any process last wrote the cache file, or zero if it hasn't
+// been written. Only the fetcher writes it, and only when something changed.
+func (c *StatusCache) GetFileModTime() time.Time {
+	stat, err := os.Stat(c.filePath)
+	if err != nil {
+		return time.Time{}
+	}
+	return stat.ModTime()
+}
+
+// GetLastFetchedAt returns when UpdateAll last received results in this process
+func (c *StatusCache) GetLastFetchedAt() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastFetchedAt
+}
+
 // httpClient is the HTTP client used for fetching statuses (overridable for testing)
 var httpClient = &http.Client{Timeout: 10 * time.Second}
 
-func getStatusColor(status string) *color.Color {
+// classifyStatus returns the color class of a status value. Terminal colors, exit codes
+// and the other outputs are all derived from this classification.
+func classifyStatus(status string) string {
 	if status == "" {
-		return color.New(color.FgRed)
+		return classRed
 	}
 
 	lower := strings.ToLower(status)
 
 	for _, s := range redStatuses {
 		if lower == s {
-			return color.New(color.FgRed)
+			return classRed
 		}
 	}
 	for _, s := range greenStatuses {
 		if lower == s {
-			return color.New(color.FgGreen)
+			return classGreen
 		}
 	}
 	for _, s := range blueStatuses {
 		if lower == s {
-			return color.New(color.FgBlue)
+			return classBlue
 		}
 	}
 
+	return classWhite
+}
+
+func getStatusColor(status string) *color.Color {
+	switch classifyStatus(status) {
+	case classRed:
+		return color.New(color.FgRed)
+	case classGreen:
+		return color.New(color.FgGreen)
+	case classBlue:
+		return color.New(color.FgBlue)
+	}
 	return color.New(color.FgWhite)
 }
 
-func fetchStatus(region string) statusResult {
-	url := statusURLs[region]
+// Limits for what a status endpoint is expected to return
+const (
+	maxBodyBytes     = 4096 // bytes read from a response body
+	maxStatusLength  = 64   // longest body accepted as a status value
+	maxSnippetLength = 80   // longest body snippet kept in an error
+)
+
+// HTTPStatusError is returned when a status endpoint responds with a non-2xx code
+type HTTPStatusError struct {
+	StatusCode int
+	Snippet    string
+}
+
+func (e *HTTPStatusError) Error() string {
+	if e.Snippet == "" {
+		return fmt.Sprintf("HTTP %d", e.StatusCode)
+	}
+	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Snippet)
+}
+
+// MalformedStatusError is returned when a response body doesn't look like a status value
+type MalformedStatusError struct {
+	Reason  string
+	Snippet string
+}
+
+func (e *MalformedStatusError) Error() string {
+	return fmt.Sprintf("malformed status (%s): %s", e.Reason, e.Snippet)
+}
+
+// snippet collapses whitespace in body and truncates it for use in error messages, without
+// splitting a multi-byte character
+func snippet(body string) string {
+	s := strings.Join(strings.Fields(body), " ")
+	if len(s) > maxSnippetLength {
+		end := maxSnippetLength
+		for end > 0 && !utf8.RuneStart(s[end]) {
+			end--
+		}
+		s = s[:end] + "..."
+	}
+	return s
+}
+
+// validateStatus rejects bodies that can't be a status value, such as error pages
+func validateStatus(body string) error {
+	lower := strings.ToLower(body)
+	switch {
+	case strings.HasPrefix(lower, "<") || strings.Contains(lower, "<html"):
+		return &MalformedStatusError{Reason: "html", Snippet: snippet(body)}
+	case strings.ContainsAny(body, "\r\n"):
+		return &MalformedStatusError{Reason: "multiple lines", Snippet: snippet(body)}
+	case len(body) > maxStatusLength:
+		return &MalformedStatusError{Reason: "too long", Snippet: snippet(body)}
+	}
+	return nil
+}
 
-	resp, err := httpClient.Get(url)
+// fetchStatus fetches a region's status, retrying transient failures per the configured
+// retry policy. Cancelling ctx abandons the request and any retries.
+func fetchStatus(ctx context.Context, region string) statusResult {
+	cfg := currentConfig()
+	r, _ := cfg.Region(region)
+	url := r.URL
+	policy := cfg.Fetch
+
+	if policy.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, policy.Deadline)
+		defer cancel()
+	}
+
+	result := statusResult{region: region}
+	for {
+		result.attempts++
+		start := time.Now()
+		result.status, result.err = fetchOnce(ctx, url)
+		metrics.observeFetch(region, time.Since(start), result.err)
+		if result.err == nil || !isTransient(result.err) || result.attempts >= policy.Attempts {
+			return result
+		}
+		if !sleepContext(ctx, policy.delay(result.attempts)) {
+			return result
+		}
+	}
+}
+
+// fetchOnce makes a single request for a status URL and validates the response
+func fetchOnce(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return statusResult{region: region, err: err}
+		return "", err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	// Read one byte past the limit so oversized bodies can be detected
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodyBytes+1))
 	if err != nil {
-		return statusResult{region: region, err: err}
+		return "", err
 	}
 
-	return statusResult{region: region, status: strings.TrimSpace(string(body))}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", &HTTPStatusError{StatusCode: resp.StatusCode, Snippet: snippet(string(body))}
+	}
+
+	if len(body) > maxBodyBytes {
+		return "", &MalformedStatusError{Reason: "too long", Snippet: snippet(string(body))}
+	}
+
+	status := strings.TrimSpace(string(body))
+	if err := validateStatus(status); err != nil {
+		return "", err
+	}
+
+	return status, nil
 }
 
-func fetchAllStatuses(cache *StatusCache) {
+// fetchAllStatuses fetches every region and stores the results in cache. Results of a fetch
+// cancelled through ctx are incomplete, so they're not stored.
+func fetchAllStatuses(ctx context.Context, cache *StatusCache) {
 	results := make(map[string]statusResult)
 	var mu sync.Mutex
 	var wg sync.WaitGroup
 
-	for _, region := range regions {
+	for _, region := range currentConfig().RegionIDs() {
 		wg.Add(1)
 		go func(r string) {
 			defer wg.Done()
-			result := fetchStatus(r)
+			result := fetchStatus(ctx, r)
 			mu.Lock()
 			results[r] = result
 			mu.Unlock()
@@ -325,6 +571,9 @@ func fetchAllStatuses(cache *StatusCache) {
 	}
 
 	wg.Wait()
+	if ctx.Err() != nil {
+		return
+	}
 	cache.UpdateAll(results)
 }
 
@@ -336,89 +585,173 @@ func printStatus(cache *StatusCache, showTimestamp bool) {
 	bold := color.New(color.Bold)
 	gray := color.New(color.FgHiBlack)
 
+	cfg := currentConfig()
 	statuses := cache.GetAll()
+	history, _ := cache.History().Entries() // Without history there are just no ETAs
+	acks := acknowledgedRegions(cache)
+	now := time.Now()
 
 	bold.Println("CSuite Deploy Status")
 	fmt.Println()
 
-	// Overall status
-	result := statuses["overall"]
-	value := result.status
-	if result.err != nil {
-		value = result.err.Error()
-	}
-	statusColor := getStatusColor(result.status)
-	if result.err != nil {
-		statusColor = color.New(color.FgRed)
-	}
-
-	fmt.Printf("%-10s ", "Status")
-	statusColor.Println(value)
-
-	// Regional statuses
-	for _, region := range []string{"au", "ca", "or", "us"} {
+	for _, region := range cfg.RegionIDs() {
 		result := statuses[region]
 		value := result.status
 		if result.err != nil {
 			value = result.err.Error()
+			if result.attempts > 1 {
+				value = fmt.Sprintf("%s (after %d attempts)", value, result.attempts)
+			}
 		}
 		statusColor := getStatusColor(result.status)
 		if result.err != nil {
 			statusColor = color.New(color.FgRed)
 		}
 
-		fmt.Printf("%-10s ", strings.ToUpper(region))
+		fmt.Printf("%-10s ", cfg.Label(region))
+		if ack, ok := acks[region]; ok {
+			color.New(color.FgYellow).Print(value)
+			gray.Printf("  %s\n", formatAck(ack, now))
+			continue
+		}
+		if result.err == nil && regionState(result) == stateInProgress {
+			if eta, ok := estimateCompletion(history, region, result.status, now); ok {
+				statusColor.Print(value)
+				gray.Printf("  %s\n", formatETA(eta))
+				continue
+			}
+		}
 		statusColor.Println(value)
 	}
 
+	printAlerts(cfg, cache)
+
 	if showTimestamp {
 		fmt.Println()
 		if lastRead := cache.GetLastReadAt(); !lastRead.IsZero() {
 			gray.Printf("last cache read:  %s\n", lastRead.Format("Mon Jan 2 15:04:05 2006"))
 		}
-		gray.Printf("last cache write: %s\n", cache.GetLastWrittenAt().Format("Mon Jan 2 15:04:05 2006"))
+		// A process that isn't the fetcher goes by when the fetcher last wrote the cache
+		lastWrite := cache.GetLastWrittenAt()
+		if lastWrite.IsZero() {
+			lastWrite = cache.GetFileModTime()
+		}
+		if !lastWrite.IsZero() {
+			gray.Printf("last cache write: %s\n", lastWrite.Format("Mon Jan 2 15:04:05 2006"))
+		}
 		fmt.Println()
 		gray.Println("Ctrl+C to exit")
 	}
 }
 
+// printAlerts lists the alerts currently firing below the statuses
+func printAlerts(cfg *Config, cache *StatusCache) {
+	if len(cfg.Alerts) == 0 {
+		return
+	}
+	firing, _ := firingAlerts(alertsPath(cache)) // Alerts are extra, show the statuses regardless
+	if len(firing) == 0 {
+		return
+	}
+
+	red := color.New(color.FgRed, color.Bold)
+	gray := color.New(color.FgHiBlack)
+	fmt.Println()
+	for _, alert := range firing {
+		line := describeAlert(cfg, alert)
+		if cache.Silences().Silenced(alert.Region, alert.Rule) {
+			gray.Println(line + " (silenced)")
+			continue
+		}
+		red.Println(line)
+	}
+}
+
+// initialize applies the config file and opens the on-disk cache, reporting errors to stderr
+func initialize(configPath string) (*StatusCache, bool) {
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		return nil, false
+	}
+	applyConfig(cfg)
+
+	cache, err := NewStatusCache()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error initializing cache: %v\n", err)
+		return nil, false
+	}
+	return cache, true
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "wait":
+			os.Exit(runWait(os.Args[2:]))
+		case "history":
+			os.Exit(runHistory(os.Args[2:]))
+		case "stats":
+			os.Exit(runStats(os.Args[2:]))
+		case "serve":
+			os.Exit(runServe(os.Args[2:]))
+		case "ack":
+			os.Exit(runAck(os.Args[2:]))
+		}
+	}
+
 	watch := flag.Bool("watch", false, "Continuously refresh status")
+	configPath := flag.String("config", "", "Path to config file (default: user config dir)")
+	output := flag.String("output", outputText, "Output format: text, json, or ndjson")
+	failOnFlag := flag.String("fail-on", defaultFailOn, "States that produce a non-zero exit code, or none")
+	textfile := flag.String("textfile", "", "In watch mode, write Prometheus metrics to this file for node_exporter")
+	onChange := flag.String("on-change", "", "In watch mode, run this command whenever a region's status changes")
+	intervals := addPollFlags(flag.CommandLine, true)
 	flag.Parse()
 
-	cache, err := NewStatusCache()
+	if err := validateOutput(*output, *watch); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	if *onChange != "" && !*watch {
+		fmt.Fprintln(os.Stderr, "Error: --on-change requires --watch")
+		os.Exit(exitUsage)
+	}
+
+	failOn, err := parseFailOn(*failOnFlag)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error initializing cache: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	cache, ok := initialize(*configPath)
+	if !ok {
+		os.Exit(exitError)
+	}
+	if err := intervals.apply(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitUsage)
 	}
 
 	if *watch {
-		// Initial fetch before displaying
-		fetchAllStatuses(cache)
-
-		// Background goroutine for fetching (write logic)
-		// Uses variable interval: 30s when active, 85s when complete
-		go func() {
-			for {
-				interval := 85 * time.Second
-				if result, ok := cache.Get("overall"); ok && strings.ToLower(result.status) != "complete" {
-					interval = 30 * time.Second
-				}
-				time.Sleep(interval)
-				fetchAllStatuses(cache)
-			}
-		}()
-
-		// Main loop for displaying (read logic)
-		// Always refreshes every 30 seconds
-		for {
-			cache.Reload()
-			clearScreen()
-			printStatus(cache, true)
-			time.Sleep(30 * time.Second)
-		}
+		ctx, stop := shutdownContext()
+		code := runWatch(ctx, cache, watchOptions{
+			configPath: *configPath,
+			output:     *output,
+			textfile:   *textfile,
+			onChange:   *onChange,
+			intervals:  intervals,
+		})
+		stop() // os.Exit skips deferred calls
+		os.Exit(code)
 	} else {
-		fetchAllStatuses(cache)
-		printStatus(cache, false)
+		fetchAllStatuses(context.Background(), cache)
+		if *output == outputText {
+			printStatus(cache, false)
+		} else if err := writeSnapshot(os.Stdout, cache, *output); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+			os.Exit(exitError)
+		}
+		os.Exit(exitCodeFor(cache.GetAll(), failOn, acknowledgedRegions(cache)))
 	}
 }
@@ -2,13 +2,18 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"github.com/fatih/color"
 )
@@ -19,8 +24,9 @@ type mockTransport struct {
 }
 
 type mockResponse struct {
-	body string
-	err  error
+	body       string
+	statusCode int // defaults to 200
+	err        error
 }
 
 func (m *mockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
@@ -33,12 +39,48 @@ func (m *mockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 		return nil, resp.err
 	}
 
+	statusCode := resp.statusCode
+	if statusCode == 0 {
+		statusCode = 200
+	}
+
 	return &http.Response{
-		StatusCode: 200,
+		StatusCode: statusCode,
 		Body:       io.NopCloser(bytes.NewBufferString(resp.body)),
 	}, nil
 }
 
+// withConfig makes a copy of the active config, modified by change, active for the rest of
+// the test
+func withConfig(t *testing.T, change func(cfg *Config)) {
+	t.Helper()
+	original := currentConfig()
+	t.Cleanup(func() { applyConfig(original) })
+
+	cfg := *original
+	change(&cfg)
+	applyConfig(&cfg)
+}
+
+// statusURL returns a region's status URL in the active config
+func statusURL(region string) string {
+	r, _ := currentConfig().Region(region)
+	return r.URL
+}
+
+// useFastRetries replaces the retry policy with short delays for the duration of a test
+func useFastRetries(t *testing.T) {
+	t.Helper()
+	withConfig(t, func(cfg *Config) {
+		cfg.Fetch = RetryPolicy{
+			Attempts:   3,
+			Backoff:    time.Millisecond,
+			MaxBackoff: 5 * time.Millisecond,
+			Deadline:   time.Second,
+		}
+	})
+}
+
 func TestGetStatusColor_RedStatuses(t *testing.T) {
 	tests := []string{"testfail", "error", "TESTFAIL", "Error"}
 	expected := color.New(color.FgRed)
@@ -103,12 +145,12 @@ func TestFetchStatus_Success(t *testing.T) {
 	httpClient = &http.Client{
 		Transport: &mockTransport{
 			responses: map[string]mockResponse{
-				statusURLs["overall"]: {body: "complete\n"},
+				statusURL("overall"): {body: "complete\n"},
 			},
 		},
 	}
 
-	result := fetchStatus("overall")
+	result := fetchStatus(context.Background(), "overall")
 
 	if result.err != nil {
 		t.Errorf("unexpected error: %v", result.err)
@@ -128,12 +170,12 @@ func TestFetchStatus_Error(t *testing.T) {
 	httpClient = &http.Client{
 		Transport: &mockTransport{
 			responses: map[string]mockResponse{
-				statusURLs["au"]: {err: errors.New("connection refused")},
+				statusURL("au"): {err: errors.New("connection refused")},
 			},
 		},
 	}
 
-	result := fetchStatus("au")
+	result := fetchStatus(context.Background(), "au")
 
 	if result.err == nil {
 		t.Error("expected error, got nil")
@@ -146,13 +188,140 @@ func TestFetchStatus_Error(t *testing.T) {
 	}
 }
 
+func TestFetchStatus_Non2xxIsHTTPStatusError(t *testing.T) {
+	useFastRetries(t)
+	originalClient := httpClient
+	defer func() { httpClient = originalClient }()
+
+	tests := map[int]string{
+		404: "Not Found",
+		502: "<html><body><h1>502 Bad Gateway</h1></body></html>",
+		503: "",
+	}
+
+	for code, body := range tests {
+		httpClient = &http.Client{
+			Transport: &mockTransport{
+				responses: map[string]mockResponse{
+					statusURL("au"): {body: body, statusCode: code},
+				},
+			},
+		}
+
+		result := fetchStatus(context.Background(), "au")
+
+		var httpErr *HTTPStatusError
+		if !errors.As(result.err, &httpErr) {
+			t.Errorf("HTTP %d: expected *HTTPStatusError, got %v", code, result.err)
+			continue
+		}
+		if httpErr.StatusCode != code {
+			t.Errorf("HTTP %d: expected StatusCode %d, got %d", code, code, httpErr.StatusCode)
+		}
+		if result.status != "" {
+			t.Errorf("HTTP %d: expected empty status, got %q", code, result.status)
+		}
+	}
+}
+
+func TestFetchStatus_HTTPStatusErrorTruncatesSnippet(t *testing.T) {
+	useFastRetries(t)
+	originalClient := httpClient
+	defer func() { httpClient = originalClient }()
+
+	httpClient = &http.Client{
+		Transport: &mockTransport{
+			responses: map[string]mockResponse{
+				statusURL("au"): {body: strings.Repeat("maintenance ", 50), statusCode: 503},
+			},
+		},
+	}
+
+	result := fetchStatus(context.Background(), "au")
+
+	var httpErr *HTTPStatusError
+	if !errors.As(result.err, &httpErr) {
+		t.Fatalf("expected *HTTPStatusError, got %v", result.err)
+	}
+	if len(httpErr.Snippet) > maxSnippetLength+len("...") {
+		t.Errorf("expected snippet to be truncated, got %d bytes", len(httpErr.Snippet))
+	}
+	if !strings.HasPrefix(httpErr.Snippet, "maintenance maintenance") {
+		t.Errorf("unexpected snippet %q", httpErr.Snippet)
+	}
+}
+
+func TestSnippet_KeepsCharactersWhole(t *testing.T) {
+	got := snippet("a" + strings.Repeat("é", 50))
+	if !utf8.ValidString(got) {
+		t.Errorf("expected a valid UTF-8 snippet, got %q", got)
+	}
+	if len(got) > maxSnippetLength+len("...") || !strings.HasSuffix(got, "é...") {
+		t.Errorf("expected the snippet to be truncated on a character boundary, got %q", got)
+	}
+}
+
+func TestFetchStatus_MalformedBodies(t *testing.T) {
+	originalClient := httpClient
+	defer func() { httpClient = originalClient }()
+
+	tests := map[string]string{
+		"html":           "<!DOCTYPE html>\n<html><body>Down for maintenance</body></html>",
+		"multiple lines": "complete\ntesting",
+		"too long":       strings.Repeat("a", maxStatusLength+1),
+		"oversized body": strings.Repeat("a", maxBodyBytes+10),
+	}
+
+	for name, body := range tests {
+		httpClient = &http.Client{
+			Transport: &mockTransport{
+				responses: map[string]mockResponse{
+					statusURL("us"): {body: body},
+				},
+			},
+		}
+
+		result := fetchStatus(context.Background(), "us")
+
+		var malformed *MalformedStatusError
+		if !errors.As(result.err, &malformed) {
+			t.Errorf("%s: expected *MalformedStatusError, got %v", name, result.err)
+		}
+		if result.status != "" {
+			t.Errorf("%s: expected empty status, got %q", name, result.status)
+		}
+	}
+}
+
+func TestFetchStatus_TrailingNewlineIsNotMultiline(t *testing.T) {
+	originalClient := httpClient
+	defer func() { httpClient = originalClient }()
+
+	httpClient = &http.Client{
+		Transport: &mockTransport{
+			responses: map[string]mockResponse{
+				statusURL("us"): {body: "  deploy\r\n"},
+			},
+		},
+	}
+
+	result := fetchStatus(context.Background(), "us")
+
+	if result.err != nil {
+		t.Errorf("unexpected error: %v", result.err)
+	}
+	if result.status != "deploy" {
+		t.Errorf("expected 'deploy', got %q", result.status)
+	}
+}
+
 func TestFetchAllStatuses_ReturnsAllRegions(t *testing.T) {
 	originalClient := httpClient
 	defer func() { httpClient = originalClient }()
 
 	responses := make(map[string]mockResponse)
-	for _, region := range regions {
-		responses[statusURLs[region]] = mockResponse{body: "complete"}
+	for _, region := range currentConfig().RegionIDs() {
+		responses[statusURL(region)] = mockResponse{body: "complete"}
 	}
 
 	httpClient = &http.Client{
@@ -161,10 +330,10 @@ func TestFetchAllStatuses_ReturnsAllRegions(t *testing.T) {
 
 	tmpFile := filepath.Join(t.TempDir(), "statuses.json")
 	cache := NewStatusCacheWithPath(tmpFile)
-	fetchAllStatuses(cache)
+	fetchAllStatuses(context.Background(), cache)
 	results := cache.GetAll()
 
-	for _, region := range regions {
+	for _, region := range currentConfig().RegionIDs() {
 		result, ok := results[region]
 		if !ok {
 			t.Errorf("missing region %q in results", region)
@@ -186,18 +355,18 @@ func TestFetchAllStatuses_MixedResults(t *testing.T) {
 	httpClient = &http.Client{
 		Transport: &mockTransport{
 			responses: map[string]mockResponse{
-				statusURLs["overall"]: {body: "testing"},
-				statusURLs["au"]:      {body: "complete"},
-				statusURLs["ca"]:      {err: errors.New("timeout")},
-				statusURLs["or"]:      {body: "pr"},
-				statusURLs["us"]:      {body: "building"},
+				statusURL("overall"): {body: "testing"},
+				statusURL("au"):      {body: "complete"},
+				statusURL("ca"):      {err: errors.New("timeout")},
+				statusURL("or"):      {body: "pr"},
+				statusURL("us"):      {body: "building"},
 			},
 		},
 	}
 
 	tmpFile := filepath.Join(t.TempDir(), "statuses.json")
 	cache := NewStatusCacheWithPath(tmpFile)
-	fetchAllStatuses(cache)
+	fetchAllStatuses(context.Background(), cache)
 	results := cache.GetAll()
 
 	if results["overall"].status != "testing" {
@@ -217,6 +386,37 @@ func TestFetchAllStatuses_MixedResults(t *testing.T) {
 	}
 }
 
+// blockingTransport holds every request until it's cancelled
+type blockingTransport struct{}
+
+func (blockingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	<-req.Context().Done()
+	return nil, req.Context().Err()
+}
+
+func TestFetchAllStatuses_CancelledIsNotCached(t *testing.T) {
+	originalClient := httpClient
+	defer func() { httpClient = originalClient }()
+	httpClient = &http.Client{Transport: blockingTransport{}}
+
+	tmpFile := filepath.Join(t.TempDir(), "statuses.json")
+	cache := NewStatusCacheWithPath(tmpFile)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	fetchAllStatuses(ctx, cache)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected requests to be abandoned when cancelled, took %v", elapsed)
+	}
+	if results := cache.GetAll(); len(results) != 0 {
+		t.Errorf("expected cancelled results not to be cached, got %v", results)
+	}
+	if _, err := os.Stat(tmpFile); !os.IsNotExist(err) {
+		t.Errorf("expected no cache file to be written, got %v", err)
+	}
+}
+
 func TestIntegration_FetchRealStatuses(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping integration test in short mode")
@@ -224,7 +424,7 @@ func TestIntegration_FetchRealStatuses(t *testing.T) {
 
 	tmpFile := filepath.Join(t.TempDir(), "statuses.json")
 	cache := NewStatusCacheWithPath(tmpFile)
-	fetchAllStatuses(cache)
+	fetchAllStatuses(context.Background(), cache)
 	results := cache.GetAll()
 
 	for region, result := range results {
@@ -375,6 +575,38 @@ func TestStatusCache_Reload(t *testing.T) {
 	}
 }
 
+func TestStatusCache_UpdateAllDuringReloadWritesNewStatuses(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "statuses.json")
+	cache := NewStatusCacheWithPath(tmpFile)
+
+	// Readers such as serve's handlers reload while the fetcher updates
+	stop := make(chan struct{})
+	reloaded := make(chan struct{})
+	go func() {
+		defer close(reloaded)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				cache.Reload()
+			}
+		}
+	}()
+
+	statuses := []string{"building", "testing", "deploy", "complete"}
+	for i := 0; i < 200; i++ {
+		status := statuses[i%len(statuses)]
+		cache.UpdateAll(map[string]statusResult{"overall": {region: "overall", status: status}})
+		if result, _ := NewStatusCacheWithPath(tmpFile).Get("overall"); result.status != status {
+			t.Errorf("update %d: expected %q on disk, got %q", i, status, result.status)
+			break
+		}
+	}
+	close(stop)
+	<-reloaded
+}
+
 func TestStatusCache_UpdateAllSkipsWriteWhenNoChanges(t *testing.T) {
 	tmpFile := filepath.Join(t.TempDir(), "statuses.json")
 	cache := NewStatusCacheWithPath(tmpFile)
@@ -481,6 +713,53 @@ func TestStatusCache_GetLastWrittenAt(t *testing.T) {
 	}
 }
 
+// captureStdout returns what fn prints to stdout, colored or not
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	originalStdout, originalOutput := os.Stdout, color.Output
+	os.Stdout, color.Output = w, w
+	defer func() { os.Stdout, color.Output = originalStdout, originalOutput }()
+
+	out := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- string(data)
+	}()
+	fn()
+	w.Close()
+	return <-out
+}
+
+func TestPrintStatus_LastWriteFromAnotherProcess(t *testing.T) {
+	withoutColor(t)
+	tmpFile := filepath.Join(t.TempDir(), "statuses.json")
+	NewStatusCacheWithPath(tmpFile).UpdateAll(map[string]statusResult{
+		"overall": {region: "overall", status: "testing"},
+	})
+
+	// This process only reads the cache the fetcher wrote
+	cache := NewStatusCacheWithPath(tmpFile)
+	output := captureStdout(t, func() { printStatus(cache, true) })
+
+	modTime := cache.GetFileModTime().Format("Mon Jan 2 15:04:05 2006")
+	if !strings.Contains(output, "last cache write: "+modTime) {
+		t.Errorf("expected the cache file's modification time as the last write, got %q", output)
+	}
+	if strings.Contains(output, "0001") {
+		t.Errorf("expected no zero time, got %q", output)
+	}
+
+	// Before anything writes the cache there's no last write to show
+	empty := NewStatusCacheWithPath(filepath.Join(t.TempDir(), "statuses.json"))
+	if output := captureStdout(t, func() { printStatus(empty, true) }); strings.Contains(output, "last cache write") {
+		t.Errorf("expected no last write before the cache is written, got %q", output)
+	}
+}
+
 func TestStatusCache_UpdateAllDetectsErrorChanges(t *testing.T) {
 	tmpFile := filepath.Join(t.TempDir(), "statuses.json")
 	cache := NewStatusCacheWithPath(tmpFile)
@@ -532,3 +811,33 @@ func TestStatusCache_UpdateAllDetectsErrorChanges(t *testing.T) {
 		t.Error("expected file to be written when error cleared")
 	}
 }
+
+func TestStatusCache_ConcurrentUpdatesSaveInOrder(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "statuses.json")
+	cache := NewStatusCacheWithPath(tmpFile)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				cache.Update(statusResult{region: "overall", status: fmt.Sprintf("status-%d-%d", g, i)})
+				if i%10 == 0 {
+					cache.Reload()
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	inMemory, _ := cache.Get("overall")
+	onDisk, _ := NewStatusCacheWithPath(tmpFile).Get("overall")
+	if inMemory.status != onDisk.status {
+		t.Errorf("expected the last change on disk, got %q in memory and %q on disk", inMemory.status, onDisk.status)
+	}
+	cache.Reload()
+	if reloaded, _ := cache.Get("overall"); reloaded.status != inMemory.status {
+		t.Errorf("expected Reload to keep %q, got %q", inMemory.status, reloaded.status)
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fetchLatencyBuckets are the upper bounds, in seconds, of the fetch latency histogram
+var fetchLatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Fetch error kinds used as the kind label of deploy_status_fetch_errors_total
+const (
+	errorKindHTTP      = "http"
+	errorKindMalformed = "malformed"
+	errorKindTimeout   = "timeout"
+	errorKindNetwork   = "network"
+)
+
+// metricStatuses are the status values exported for every region, so the set of
+// deploy_status_region_status series stays the same as statuses change
+var metricStatuses = []string{"pr", "building", "testing", "testok", "testfail", "merging", "deploy", "complete", "error"}
+
+// histogram is a cumulative Prometheus-style histogram
+type histogram struct {
+	counts []uint64 // observations per bucket in fetchLatencyBuckets, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// processMetrics holds the counters collected by this process. Statuses are read from the
+// cache when metrics are written, but fetch and write counts only cover this process.
+type processMetrics struct {
+	mu          sync.Mutex
+	latency     map[string]*histogram        // by region
+	errors      map[string]map[string]uint64 // by region, then kind
+	cacheWrites uint64
+}
+
+// metrics collects fetch and cache metrics for the running process
+var metrics = newProcessMetrics()
+
+func newProcessMetrics() *processMetrics {
+	return &processMetrics{
+		latency: make(map[string]*histogram),
+		errors:  make(map[string]map[string]uint64),
+	}
+}
+
+// observeFetch records one fetch attempt for region
+func (m *processMetrics) observeFetch(region string, d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	h, ok := m.latency[region]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(fetchLatencyBuckets))}
+		m.latency[region] = h
+	}
+	seconds := d.Seconds()
+	h.count++
+	h.sum += seconds
+	for i, bound := range fetchLatencyBuckets {
+		if seconds <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+
+	if err != nil {
+		if m.errors[region] == nil {
+			m.errors[region] = make(map[string]uint64)
+		}
+		m.errors[region][errorKind(err)]++
+	}
+}
+
+// observeCacheWrite records the cache being written to disk
+func (m *processMetrics) observeCacheWrite() {
+	m.mu.Lock()
+	m.cacheWrites++
+	m.mu.Unlock()
+}
+
+// errorKind classifies a fetch error for the error counters
+func errorKind(err error) string {
+	var httpErr *HTTPStatusError
+	var malformedErr *MalformedStatusError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &httpErr):
+		return errorKindHTTP
+	case errors.As(err, &malformedErr):
+		return errorKindMalformed
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return errorKindTimeout
+	}
+	return errorKindNetwork
+}
+
+// labelEscaper escapes label values for the Prometheus text format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labels formats name/value pairs as a Prometheus label set, e.g. {region="au"}
+func labels(pairs ...string) string {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, pairs[i], labelEscaper.Replace(pairs[i+1]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// formatFloat formats a sample value for the Prometheus text format
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// writeMetrics writes the cached statuses and this process's counters to w in the
+// Prometheus text exposition format
+func writeMetrics(w io.Writer, cache *StatusCache, now time.Time) error {
+	var buf bytes.Buffer
+	statuses := cache.GetAll()
+
+	// Seconds since the last change come from the history, which every process shares. A
+	// first observation only says when a region was first fetched, so it doesn't count.
+	history, err := cache.History().Entries()
+	if err != nil {
+		return err
+	}
+	lastChange := make(map[string]transition)
+	for _, entry := range history {
+		if entry.From != "" {
+			lastChange[entry.Region] = entry
+		}
+	}
+
+	regions := currentConfig().RegionIDs()
+	fmt.Fprintln(&buf, "# HELP deploy_status_region_status Deployment status of a region, 1 for the current status and 0 for every other known status.")
+	fmt.Fprintln(&buf, "# TYPE deploy_status_region_status gauge")
+	for _, region := range regions {
+		result, ok := statuses[region]
+		if !ok || result.err != nil {
+			continue
+		}
+		for _, status := range metricStatuses {
+			value := 0
+			if status == result.status {
+				value = 1
+			}
+			fmt.Fprintf(&buf, "deploy_status_region_status%s %d\n", labels("region", region, "status", status), value)
+		}
+		if !slices.Contains(metricStatuses, result.status) {
+			fmt.Fprintf(&buf, "deploy_status_region_status%s 1\n", labels("region", region, "status", result.status))
+		}
+	}
+
+	fmt.Fprintln(&buf, "# HELP deploy_status_region_up Whether the last fetch of a region's status succeeded.")
+	fmt.Fprintln(&buf, "# TYPE deploy_status_region_up gauge")
+	for _, region := range regions {
+		result, ok := statuses[region]
+		if !ok {
+			continue
+		}
+		up := 1
+		if result.err != nil {
+			up = 0
+		}
+		fmt.Fprintf(&buf, "deploy_status_region_up%s %d\n", labels("region", region), up)
+	}
+
+	fmt.Fprintln(&buf, "# HELP deploy_status_seconds_since_change Seconds since a region's status last changed.")
+	fmt.Fprintln(&buf, "# TYPE deploy_status_seconds_since_change gauge")
+	for _, region := range regions {
+		entry, ok := lastChange[region]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(&buf, "deploy_status_seconds_since_change%s %s\n", labels("region", region), formatFloat(now.Sub(entry.ChangedAt).Seconds()))
+	}
+
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+
+	fmt.Fprintln(&buf, "# HELP deploy_status_fetch_duration_seconds Time taken by each fetch attempt.")
+	fmt.Fprintln(&buf, "# TYPE deploy_status_fetch_duration_seconds histogram")
+	for _, region := range sortedKeys(metrics.latency) {
+		h := metrics.latency[region]
+		var cumulative uint64
+		for i, bound := range fetchLatencyBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(&buf, "deploy_status_fetch_duration_seconds_bucket%s %d\n", labels("region", region, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(&buf, "deploy_status_fetch_duration_seconds_bucket%s %d\n", labels("region", region, "le", "+Inf"), h.count)
+		fmt.Fprintf(&buf, "deploy_status_fetch_duration_seconds_sum%s %s\n", labels("region", region), formatFloat(h.sum))
+		fmt.Fprintf(&buf, "deploy_status_fetch_duration_seconds_count%s %d\n", labels("region", region), h.count)
+	}
+
+	fmt.Fprintln(&buf, "# HELP deploy_status_fetch_errors_total Failed fetch attempts by kind of error.")
+	fmt.Fprintln(&buf, "# TYPE deploy_status_fetch_errors_total counter")
+	for _, region := range sortedKeys(metrics.errors) {
+		for _, kind := range sortedKeys(metrics.errors[region]) {
+			fmt.Fprintf(&buf, "deploy_status_fetch_errors_total%s %d\n", labels("region", region, "kind", kind), metrics.errors[region][kind])
+		}
+	}
+
+	fmt.Fprintln(&buf, "# HELP deploy_status_cache_writes_total Times this process wrote the status cache to disk.")
+	fmt.Fprintln(&buf, "# TYPE deploy_status_cache_writes_total counter")
+	fmt.Fprintf(&buf, "deploy_status_cache_writes_total %d\n", metrics.cacheWrites)
+
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// sortedKeys returns the keys of m in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// exportMetrics re-reads the cache and atomically writes its metrics to path, for
+// node_exporter's textfile collector
+func exportMetrics(cache *StatusCache, path string) error {
+	cache.Reload()
+
+	var buf bytes.Buffer
+	if err := writeMetrics(&buf, cache, time.Now()); err != nil {
+		return fmt.Errorf("failed to collect metrics: %w", err)
+	}
+	if err := writeFileAtomic(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write metrics: %w", err)
+	}
+	return nil
+}
+
+// handleMetrics serves metrics in the Prometheus text format
+func (s *server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	s.cache.Reload()
+
+	var buf bytes.Buffer
+	if err := writeMetrics(&buf, s.cache, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(buf.Bytes())
+}
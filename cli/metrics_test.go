@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useFreshMetrics replaces the process metrics with empty ones for the duration of a test
+func useFreshMetrics(t *testing.T) {
+	t.Helper()
+	original := metrics
+	t.Cleanup(func() { metrics = original })
+	metrics = newProcessMetrics()
+}
+
+func collectMetrics(t *testing.T, cache *StatusCache, now time.Time) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := writeMetrics(&buf, cache, now); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return buf.String()
+}
+
+func assertContains(t *testing.T, output string, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("expected output to contain %q, got:\n%s", line, output)
+		}
+	}
+}
+
+func TestErrorKind(t *testing.T) {
+	tests := []struct {
+		err  error
+		kind string
+	}{
+		{&HTTPStatusError{StatusCode: 503}, errorKindHTTP},
+		{fmt.Errorf("wrapped: %w", &MalformedStatusError{Reason: "html"}), errorKindMalformed},
+		{context.DeadlineExceeded, errorKindTimeout},
+		{timeoutError{}, errorKindTimeout},
+		{errors.New("connection refused"), errorKindNetwork},
+	}
+
+	for _, tt := range tests {
+		if kind := errorKind(tt.err); kind != tt.kind {
+			t.Errorf("errorKind(%v) = %q, expected %q", tt.err, kind, tt.kind)
+		}
+	}
+}
+
+func TestWriteMetrics_StatusGauges(t *testing.T) {
+	useFreshMetrics(t)
+	cache := NewStatusCacheWithPath(filepath.Join(t.TempDir(), "statuses.json"))
+	cache.UpdateAll(map[string]statusResult{
+		"overall": {region: "overall", status: "testing"},
+		"ca":      {region: "ca", err: errors.New("HTTP 503"), attempts: 3},
+	})
+
+	output := collectMetrics(t, cache, time.Now())
+
+	assertContains(t, output,
+		"# TYPE deploy_status_region_status gauge",
+		`deploy_status_region_status{region="overall",status="testing"} 1`,
+		`deploy_status_region_up{region="overall"} 1`,
+		`deploy_status_region_up{region="ca"} 0`,
+	)
+	if strings.Contains(output, "deploy_status_seconds_since_change{") {
+		t.Error("expected no time since the last change for a region that hasn't changed yet")
+	}
+	if strings.Contains(output, `deploy_status_region_status{region="ca"`) {
+		t.Error("expected no status gauge for a region whose fetch failed")
+	}
+	if strings.Contains(output, `region="au"`) {
+		t.Error("expected no series for a region that was never fetched")
+	}
+}
+
+func TestWriteMetrics_ChangeFromHistory(t *testing.T) {
+	useFreshMetrics(t)
+	path := filepath.Join(t.TempDir(), "statuses.json")
+	fetcher := NewStatusCacheWithPath(path)
+	fetcher.UpdateAll(map[string]statusResult{"overall": {region: "overall", status: "testing"}})
+	fetcher.UpdateAll(map[string]statusResult{"overall": {region: "overall", status: "testok"}})
+
+	// A restarted process knows the change only from the history
+	cache := NewStatusCacheWithPath(path)
+	entries, _ := cache.History().Entries()
+	changedAt := entries[len(entries)-1].ChangedAt
+	output := collectMetrics(t, cache, changedAt.Add(90*time.Second))
+
+	assertContains(t, output,
+		`deploy_status_region_status{region="overall",status="testok"} 1`,
+		`deploy_status_region_status{region="overall",status="testing"} 0`,
+		`deploy_status_region_status{region="overall",status="pr"} 0`,
+		`deploy_status_region_status{region="overall",status="complete"} 0`,
+		`deploy_status_seconds_since_change{region="overall"} 90`,
+	)
+}
+
+func TestWriteMetrics_EscapesLabelValues(t *testing.T) {
+	useFreshMetrics(t)
+	cache := NewStatusCacheWithPath(filepath.Join(t.TempDir(), "statuses.json"))
+	cache.UpdateAll(map[string]statusResult{"overall": {region: "overall", status: `say "hi"\now`}})
+
+	output := collectMetrics(t, cache, time.Now())
+	assertContains(t, output, `deploy_status_region_status{region="overall",status="say \"hi\"\\now"} 1`)
+}
+
+func TestWriteMetrics_FetchLatencyAndErrors(t *testing.T) {
+	useFreshMetrics(t)
+	useFastRetries(t)
+
+	originalClient := httpClient
+	defer func() { httpClient = originalClient }()
+	httpClient = &http.Client{Transport: &sequenceTransport{responses: []mockResponse{
+		{statusCode: 503},
+		{body: "<html>oops</html>"},
+	}}}
+
+	result := fetchStatus(context.Background(), "au")
+	if result.attempts != 2 {
+		t.Fatalf("expected 2 attempts, got %d", result.attempts)
+	}
+
+	cache := NewStatusCacheWithPath(filepath.Join(t.TempDir(), "statuses.json"))
+	output := collectMetrics(t, cache, time.Now())
+
+	assertContains(t, output,
+		"# TYPE deploy_status_fetch_duration_seconds histogram",
+		`deploy_status_fetch_duration_seconds_bucket{region="au",le="+Inf"} 2`,
+		`deploy_status_fetch_duration_seconds_count{region="au"} 2`,
+		`deploy_status_fetch_errors_total{region="au",kind="http"} 1`,
+		`deploy_status_fetch_errors_total{region="au",kind="malformed"} 1`,
+	)
+}
+
+func TestProcessMetrics_HistogramBucketsAreCumulative(t *testing.T) {
+	useFreshMetrics(t)
+	metrics.observeFetch("au", 80*time.Millisecond, nil)
+	metrics.observeFetch("au", 700*time.Millisecond, nil)
+	metrics.observeFetch("au", 30*time.Second, nil)
+
+	cache := NewStatusCacheWithPath(filepath.Join(t.TempDir(), "statuses.json"))
+	output := collectMetrics(t, cache, time.Now())
+
+	assertContains(t, output,
+		`deploy_status_fetch_duration_seconds_bucket{region="au",le="0.05"} 0`,
+		`deploy_status_fetch_duration_seconds_bucket{region="au",le="0.1"} 1`,
+		`deploy_status_fetch_duration_seconds_bucket{region="au",le="1"} 2`,
+		`deploy_status_fetch_duration_seconds_bucket{region="au",le="10"} 2`,
+		`deploy_status_fetch_duration_seconds_bucket{region="au",le="+Inf"} 3`,
+		`deploy_status_fetch_duration_seconds_sum{region="au"} 30.78`,
+	)
+}
+
+func TestWriteMetrics_CountsCacheWrites(t *testing.T) {
+	useFreshMetrics(t)
+	cache := NewStatusCacheWithPath(filepath.Join(t.TempDir(), "statuses.json"))
+
+	cache.UpdateAll(map[string]statusResult{"au": {region: "au", status: "testing"}})
+	cache.UpdateAll(map[string]statusResult{"au": {region: "au", status: "testing"}}) // Unchanged, not written
+	cache.UpdateAll(map[string]statusResult{"au": {region: "au", status: "complete"}})
+
+	output := collectMetrics(t, cache, time.Now())
+	assertContains(t, output, "deploy_status_cache_writes_total 2")
+}
+
+func TestExportMetrics_WritesTextfile(t *testing.T) {
+	useFreshMetrics(t)
+	dir := t.TempDir()
+	cache := NewStatusCacheWithPath(filepath.Join(dir, "statuses.json"))
+
+	// Another process writes the cache; the export re-reads it
+	other := NewStatusCacheWithPath(cache.filePath)
+	other.UpdateAll(map[string]statusResult{"au": {region: "au", status: "deploy"}})
+
+	path := filepath.Join(dir, "deploy_status.prom")
+	if err := exportMetrics(cache, path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read textfile: %v", err)
+	}
+	assertContains(t, string(data), `deploy_status_region_status{region="au",status="deploy"} 1`)
+}
+
+func TestServer_Metrics(t *testing.T) {
+	useFreshMetrics(t)
+	srv, _ := newTestServer(t)
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	assertContains(t, rec.Body.String(),
+		`deploy_status_region_status{region="overall",status="testing"} 1`,
+		`deploy_status_region_status{region="au",status="complete"} 1`,
+		`deploy_status_region_up{region="ca"} 0`,
+	)
+}
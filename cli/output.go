@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Output formats accepted by --output
+const (
+	outputText   = "text"
+	outputJSON   = "json"
+	outputNDJSON = "ndjson"
+)
+
+// snapshotSchemaVersion is bumped whenever a field is removed or changes meaning.
+// New fields may be added without a version bump.
+const snapshotSchemaVersion = 1
+
+// statusSnapshot is the machine-readable form of the current statuses
+type statusSnapshot struct {
+	SchemaVersion int              `json:"schemaVersion"`
+	GeneratedAt   time.Time        `json:"generatedAt"`
+	FetchedAt     *time.Time       `json:"fetchedAt,omitempty"`
+	Regions       []regionSnapshot `json:"regions"`
+}
+
+// regionSnapshot is a single region's entry in a statusSnapshot
+type regionSnapshot struct {
+	ID        string     `json:"id"`
+	Label     string     `json:"label"`
+	Status    string     `json:"status"`
+	Class     string     `json:"class"`
+	Error     string     `json:"error,omitempty"`
+	Attempts  int        `json:"attempts,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+}
+
+// validateOutput checks that format is known and usable with the chosen mode
+func validateOutput(format string, watch bool) error {
+	switch format {
+	case outputText, outputNDJSON:
+		return nil
+	case outputJSON:
+		if watch {
+			return fmt.Errorf("--output json can't be used with --watch, use --output ndjson")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q (expected text, json, or ndjson)", format)
+}
+
+// buildSnapshot collects the regions configured in cfg from the cache in display order
+func buildSnapshot(cfg *Config, cache *StatusCache) statusSnapshot {
+	statuses := cache.GetAll()
+
+	snapshot := statusSnapshot{
+		SchemaVersion: snapshotSchemaVersion,
+		GeneratedAt:   time.Now(),
+		Regions:       make([]regionSnapshot, 0, len(cfg.Regions)),
+	}
+	// A process that isn't the fetcher goes by when the fetcher last wrote the cache
+	fetchedAt := cache.GetLastFetchedAt()
+	if fetchedAt.IsZero() {
+		fetchedAt = cache.GetFileModTime()
+	}
+	if !fetchedAt.IsZero() {
+		snapshot.FetchedAt = &fetchedAt
+	}
+
+	for _, r := range cfg.Regions {
+		region := r.ID
+		result := statuses[region]
+		entry := regionSnapshot{
+			ID:       region,
+			Label:    r.Label,
+			Status:   result.status,
+			Class:    classifyStatus(result.status),
+			Attempts: result.attempts,
+		}
+		if result.err != nil {
+			entry.Error = result.err.Error()
+			entry.Class = classRed
+		}
+		if updatedAt, ok := cache.GetUpdatedAt(region); ok {
+			entry.UpdatedAt = &updatedAt
+		}
+		snapshot.Regions = append(snapshot.Regions, entry)
+	}
+
+	return snapshot
+}
+
+// writeSnapshot writes the current statuses to w as indented JSON or a single NDJSON line
+func writeSnapshot(w io.Writer, cache *StatusCache, format string) error {
+	encoder := json.NewEncoder(w)
+	if format == outputJSON {
+		encoder.SetIndent("", "  ")
+	}
+	return encoder.Encode(buildSnapshot(currentConfig(), cache))
+}
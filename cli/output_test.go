@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateOutput(t *testing.T) {
+	valid := []struct {
+		format string
+		watch  bool
+	}{
+		{outputText, false},
+		{outputText, true},
+		{outputJSON, false},
+		{outputNDJSON, false},
+		{outputNDJSON, true},
+	}
+	for _, v := range valid {
+		if err := validateOutput(v.format, v.watch); err != nil {
+			t.Errorf("validateOutput(%q, %v): unexpected error: %v", v.format, v.watch, err)
+		}
+	}
+
+	if err := validateOutput(outputJSON, true); err == nil {
+		t.Error("expected error for json output in watch mode")
+	}
+	if err := validateOutput("yaml", false); err == nil {
+		t.Error("expected error for unknown output format")
+	}
+}
+
+func TestBuildSnapshot_UpdatedAtOnlyForChangedRegions(t *testing.T) {
+	cache := NewStatusCacheWithPath(filepath.Join(t.TempDir(), "statuses.json"))
+	cache.UpdateAll(map[string]statusResult{
+		"overall": {region: "overall", status: "testing"},
+		"au":      {region: "au", status: "testing"},
+	})
+	time.Sleep(10 * time.Millisecond)
+	cache.UpdateAll(map[string]statusResult{
+		"overall": {region: "overall", status: "testing"},
+		"au":      {region: "au", status: "testok"},
+	})
+
+	updatedAt := make(map[string]time.Time)
+	for _, entry := range buildSnapshot(currentConfig(), cache).Regions {
+		if entry.UpdatedAt != nil {
+			updatedAt[entry.ID] = *entry.UpdatedAt
+		}
+	}
+	if !updatedAt["au"].After(updatedAt["overall"]) {
+		t.Errorf("expected only au's updatedAt to move on, got overall %v and au %v", updatedAt["overall"], updatedAt["au"])
+	}
+}
+
+func TestBuildSnapshot(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "statuses.json")
+	cache := NewStatusCacheWithPath(tmpFile)
+	cache.UpdateAll(map[string]statusResult{
+		"overall": {region: "overall", status: "testing"},
+		"au":      {region: "au", err: errors.New("HTTP 503"), attempts: 3},
+	})
+
+	snapshot := buildSnapshot(currentConfig(), cache)
+
+	if snapshot.SchemaVersion != snapshotSchemaVersion {
+		t.Errorf("expected schema version %d, got %d", snapshotSchemaVersion, snapshot.SchemaVersion)
+	}
+	if snapshot.FetchedAt == nil {
+		t.Error("expected fetchedAt to be set after a fetch")
+	}
+	regions := currentConfig().RegionIDs()
+	if len(snapshot.Regions) != len(regions) {
+		t.Fatalf("expected %d regions, got %d", len(regions), len(snapshot.Regions))
+	}
+	for i, region := range regions {
+		if snapshot.Regions[i].ID != region {
+			t.Errorf("expected region %d to be %q, got %q", i, region, snapshot.Regions[i].ID)
+		}
+	}
+
+	overall := snapshot.Regions[0]
+	if overall.Status != "testing" || overall.Class != classGreen || overall.Label != "Status" || overall.UpdatedAt == nil {
+		t.Errorf("unexpected overall entry: %+v", overall)
+	}
+
+	au := snapshot.Regions[1]
+	if au.Error != "HTTP 503" || au.Attempts != 3 || au.Class != classRed {
+		t.Errorf("unexpected au entry: %+v", au)
+	}
+
+	// Regions that have never been fetched have no timestamp
+	if snapshot.Regions[2].UpdatedAt != nil {
+		t.Errorf("expected no updatedAt for unfetched region, got %v", snapshot.Regions[2].UpdatedAt)
+	}
+}
+
+func TestBuildSnapshot_FetchedAtFromCacheFileInOtherProcesses(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "statuses.json")
+	if snapshot := buildSnapshot(currentConfig(), NewStatusCacheWithPath(tmpFile)); snapshot.FetchedAt != nil {
+		t.Errorf("expected no fetchedAt before anything was fetched, got %v", snapshot.FetchedAt)
+	}
+
+	NewStatusCacheWithPath(tmpFile).UpdateAll(map[string]statusResult{"overall": {region: "overall", status: "testing"}})
+	written := time.Date(2026, 1, 30, 15, 4, 5, 0, time.UTC)
+	os.Chtimes(tmpFile, written, written)
+
+	// A process that isn't the fetcher never calls UpdateAll itself
+	snapshot := buildSnapshot(currentConfig(), NewStatusCacheWithPath(tmpFile))
+	if snapshot.FetchedAt == nil || !snapshot.FetchedAt.Equal(written) {
+		t.Errorf("expected fetchedAt from the cache file, got %v", snapshot.FetchedAt)
+	}
+}
+
+func TestWriteSnapshot_NDJSONIsOneLine(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "statuses.json")
+	cache := NewStatusCacheWithPath(tmpFile)
+	cache.UpdateAll(map[string]statusResult{
+		"overall": {region: "overall", status: "testfail"},
+	})
+
+	var buf bytes.Buffer
+	if err := writeSnapshot(&buf, cache, outputNDJSON); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out := buf.String()
+	if strings.Count(out, "\n") != 1 || !strings.HasSuffix(out, "\n") {
+		t.Errorf("expected a single line, got %q", out)
+	}
+	if strings.Contains(out, "\033[") {
+		t.Error("expected no ANSI escape sequences in output")
+	}
+
+	var decoded statusSnapshot
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("output is not valid JSON: %v", err)
+	}
+	if decoded.Regions[0].Status != "testfail" {
+		t.Errorf("expected 'testfail', got %q", decoded.Regions[0].Status)
+	}
+}
+
+func TestWriteSnapshot_JSONIsIndented(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "statuses.json")
+	cache := NewStatusCacheWithPath(tmpFile)
+
+	var buf bytes.Buffer
+	if err := writeSnapshot(&buf, cache, outputJSON); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "\n  \"schemaVersion\": 1") {
+		t.Errorf("expected indented JSON, got %q", buf.String())
+	}
+	if strings.Contains(buf.String(), "Ctrl+C") {
+		t.Error("expected no footer in JSON output")
+	}
+}
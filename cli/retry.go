@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls how fetchStatus retries transient failures
+type RetryPolicy struct {
+	Attempts   int           `toml:"attempts"`    // total attempts per region, including the first
+	Backoff    time.Duration `toml:"backoff"`     // delay before the first retry, doubled on each retry
+	MaxBackoff time.Duration `toml:"max_backoff"` // upper bound for a single delay
+	Deadline   time.Duration `toml:"deadline"`    // total time allowed for all attempts
+}
+
+// defaultRetryPolicy keeps a region's total fetch time under the 30 second poll interval
+var defaultRetryPolicy = RetryPolicy{
+	Attempts:   3,
+	Backoff:    1 * time.Second,
+	MaxBackoff: 8 * time.Second,
+	Deadline:   25 * time.Second,
+}
+
+// merge returns p with any non-zero fields of o applied over it
+func (p RetryPolicy) merge(o RetryPolicy) RetryPolicy {
+	if o.Attempts != 0 {
+		p.Attempts = o.Attempts
+	}
+	if o.Backoff != 0 {
+		p.Backoff = o.Backoff
+	}
+	if o.MaxBackoff != 0 {
+		p.MaxBackoff = o.MaxBackoff
+	}
+	if o.Deadline != 0 {
+		p.Deadline = o.Deadline
+	}
+	return p
+}
+
+// delay returns the jittered wait before retry number n (starting at 1)
+func (p RetryPolicy) delay(n int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < n && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	// Equal jitter: wait between half and all of the computed delay
+	half := d / 2
+	return half + rand.N(d-half+1)
+}
+
+// isTransient reports whether a fetch error is worth retrying:
+// timeouts, connection resets and 5xx responses
+func isTransient(err error) bool {
+	var httpErr *HTTPStatusError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode >= 500
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
+
+// sleepContext waits for d or until ctx is done, reporting whether the full delay elapsed
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// sequenceTransport returns its responses in order, repeating the last one
+type sequenceTransport struct {
+	mu        sync.Mutex
+	responses []mockResponse
+	calls     int
+}
+
+func (s *sequenceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	s.mu.Lock()
+	resp := s.responses[min(s.calls, len(s.responses)-1)]
+	s.calls++
+	s.mu.Unlock()
+
+	if resp.err != nil {
+		return nil, resp.err
+	}
+
+	statusCode := resp.statusCode
+	if statusCode == 0 {
+		statusCode = 200
+	}
+	return &http.Response{
+		StatusCode: statusCode,
+		Body:       io.NopCloser(bytes.NewBufferString(resp.body)),
+	}, nil
+}
+
+// timeoutError mimics the net.Error returned for a request timeout
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestIsTransient(t *testing.T) {
+	transient := []error{
+		timeoutError{},
+		syscall.ECONNRESET,
+		io.ErrUnexpectedEOF,
+		&HTTPStatusError{StatusCode: 502},
+		&HTTPStatusError{StatusCode: 503},
+	}
+	for _, err := range transient {
+		if !isTransient(err) {
+			t.Errorf("expected %v to be transient", err)
+		}
+	}
+
+	permanent := []error{
+		errors.New("connection refused"),
+		&HTTPStatusError{StatusCode: 404},
+		&MalformedStatusError{Reason: "html"},
+	}
+	for _, err := range permanent {
+		if isTransient(err) {
+			t.Errorf("expected %v not to be transient", err)
+		}
+	}
+}
+
+func TestRetryPolicy_DelayIsBoundedAndJittered(t *testing.T) {
+	p := RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: 400 * time.Millisecond}
+
+	for i := 0; i < 100; i++ {
+		if d := p.delay(1); d < 50*time.Millisecond || d > 100*time.Millisecond {
+			t.Fatalf("delay(1) out of range: %v", d)
+		}
+		if d := p.delay(2); d < 100*time.Millisecond || d > 200*time.Millisecond {
+			t.Fatalf("delay(2) out of range: %v", d)
+		}
+		if d := p.delay(10); d < 200*time.Millisecond || d > 400*time.Millisecond {
+			t.Fatalf("delay(10) should be capped, got %v", d)
+		}
+	}
+}
+
+func TestFetchStatus_RetriesTransientFailures(t *testing.T) {
+	useFastRetries(t)
+	originalClient := httpClient
+	defer func() { httpClient = originalClient }()
+
+	transport := &sequenceTransport{responses: []mockResponse{
+		{err: timeoutError{}},
+		{body: "Bad Gateway", statusCode: 502},
+		{body: "testing"},
+	}}
+	httpClient = &http.Client{Transport: transport}
+
+	result := fetchStatus(context.Background(), "overall")
+
+	if result.err != nil {
+		t.Fatalf("unexpected error: %v", result.err)
+	}
+	if result.status != "testing" {
+		t.Errorf("expected 'testing', got %q", result.status)
+	}
+	if result.attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", result.attempts)
+	}
+}
+
+func TestFetchStatus_GivesUpAfterMaxAttempts(t *testing.T) {
+	useFastRetries(t)
+	originalClient := httpClient
+	defer func() { httpClient = originalClient }()
+
+	transport := &sequenceTransport{responses: []mockResponse{{body: "Service Unavailable", statusCode: 503}}}
+	httpClient = &http.Client{Transport: transport}
+
+	result := fetchStatus(context.Background(), "overall")
+
+	var httpErr *HTTPStatusError
+	if !errors.As(result.err, &httpErr) {
+		t.Fatalf("expected *HTTPStatusError, got %v", result.err)
+	}
+	if result.attempts != 3 || transport.calls != 3 {
+		t.Errorf("expected 3 attempts, got %d (%d requests)", result.attempts, transport.calls)
+	}
+}
+
+func TestFetchStatus_DoesNotRetryPermanentFailures(t *testing.T) {
+	useFastRetries(t)
+	originalClient := httpClient
+	defer func() { httpClient = originalClient }()
+
+	transport := &sequenceTransport{responses: []mockResponse{{body: "Not Found", statusCode: 404}}}
+	httpClient = &http.Client{Transport: transport}
+
+	result := fetchStatus(context.Background(), "overall")
+
+	if result.err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if result.attempts != 1 || transport.calls != 1 {
+		t.Errorf("expected 1 attempt, got %d (%d requests)", result.attempts, transport.calls)
+	}
+}
+
+func TestFetchStatus_StopsAtDeadline(t *testing.T) {
+	withConfig(t, func(cfg *Config) {
+		cfg.Fetch = RetryPolicy{
+			Attempts:   100,
+			Backoff:    20 * time.Millisecond,
+			MaxBackoff: 20 * time.Millisecond,
+			Deadline:   50 * time.Millisecond,
+		}
+	})
+
+	originalClient := httpClient
+	defer func() { httpClient = originalClient }()
+
+	transport := &sequenceTransport{responses: []mockResponse{{statusCode: 500}}}
+	httpClient = &http.Client{Transport: transport}
+
+	start := time.Now()
+	result := fetchStatus(context.Background(), "overall")
+
+	if result.err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected fetch to stop near the deadline, took %v", elapsed)
+	}
+	if result.attempts >= 100 {
+		t.Errorf("expected deadline to cut retries short, got %d attempts", result.attempts)
+	}
+}
+
+func TestSleepContext_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if sleepContext(ctx, time.Hour) {
+		t.Error("expected sleepContext to return false when context is done")
+	}
+}
+
+func TestStatusCache_PersistsAttempts(t *testing.T) {
+	tmpFile := filepath.Join(t.TempDir(), "statuses.json")
+
+	cache := NewStatusCacheWithPath(tmpFile)
+	cache.UpdateAll(map[string]statusResult{
+		"au": {region: "au", err: errors.New("timeout"), attempts: 3},
+	})
+
+	cache2 := NewStatusCacheWithPath(tmpFile)
+	result, ok := cache2.Get("au")
+	if !ok {
+		t.Fatal("expected to find 'au' in cache")
+	}
+	if result.attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", result.attempts)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// PollPolicy controls how often statuses are fetched and the watch display refreshed
+type PollPolicy struct {
+	Active     time.Duration            `toml:"active"`      // interval while a deployment is in progress
+	Idle       time.Duration            `toml:"idle"`        // interval once everything is complete
+	Display    time.Duration            `toml:"display"`     // longest time between plain watch display refreshes
+	MaxBackoff time.Duration            `toml:"max_backoff"` // longest interval after repeated fetch errors
+	Regions    []string                 `toml:"regions"`     // regions whose statuses choose the interval
+	Status     map[string]time.Duration `toml:"status"`      // intervals for particular status values
+}
+
+// defaultPollPolicy fetches every 30 seconds during a deployment and every 85 seconds after
+var defaultPollPolicy = PollPolicy{
+	Active:     30 * time.Second,
+	Idle:       85 * time.Second,
+	Display:    30 * time.Second,
+	MaxBackoff: 5 * time.Minute,
+}
+
+// merge returns p with any non-zero fields of o applied over it
+func (p PollPolicy) merge(o PollPolicy) PollPolicy {
+	if o.Active != 0 {
+		p.Active = o.Active
+	}
+	if o.Idle != 0 {
+		p.Idle = o.Idle
+	}
+	if o.Display != 0 {
+		p.Display = o.Display
+	}
+	if o.MaxBackoff != 0 {
+		p.MaxBackoff = o.MaxBackoff
+	}
+	if len(o.Regions) > 0 {
+		p.Regions = o.Regions
+	}
+	if len(o.Status) > 0 {
+		p.Status = make(map[string]time.Duration, len(o.Status))
+		for status, interval := range o.Status {
+			p.Status[strings.ToLower(status)] = interval
+		}
+	}
+	return p
+}
+
+// validate checks the policy's intervals are usable
+func (p PollPolicy) validate() error {
+	if p.Active <= 0 || p.Idle <= 0 || p.Display <= 0 || p.MaxBackoff <= 0 {
+		return errors.New("poll intervals must be positive")
+	}
+	for status, interval := range p.Status {
+		if interval <= 0 {
+			return fmt.Errorf("poll interval for status %q must be positive", status)
+		}
+	}
+	return nil
+}
+
+// watched returns the regions of cfg whose statuses choose the interval: the configured
+// ones, or the overall status, or every region if there's no overall region
+func (p PollPolicy) watched(cfg *Config) []string {
+	if len(p.Regions) > 0 {
+		return p.Regions
+	}
+	if _, ok := cfg.Region("overall"); ok {
+		return []string{"overall"}
+	}
+	return cfg.RegionIDs()
+}
+
+// regionInterval returns the interval a region's fetched status asks for
+func (p PollPolicy) regionInterval(result statusResult) time.Duration {
+	if result.err != nil {
+		return p.Active
+	}
+	if interval, ok := p.Status[strings.ToLower(result.status)]; ok {
+		return interval
+	}
+	if regionState(result) == stateComplete {
+		return p.Idle
+	}
+	return p.Active
+}
+
+// scheduler decides when the next fetch is due. The interval is the shortest any watched
+// region asks for. When every watched region fails to fetch, the interval doubles with each
+// failed fetch, up to the policy's maximum backoff.
+type scheduler struct {
+	policy    PollPolicy
+	now       func() time.Time
+	nextFetch time.Time
+	failures  int // consecutive fetches where every watched region failed
+}
+
+// newScheduler creates a scheduler with a fetch due straight away
+func newScheduler(policy PollPolicy) *scheduler {
+	return &scheduler{policy: policy, now: time.Now}
+}
+
+// due reports whether it's time to fetch
+func (s *scheduler) due() bool {
+	return !s.now().Before(s.nextFetch)
+}
+
+// until returns how long until the next fetch is due, zero if it's already due
+func (s *scheduler) until() time.Duration {
+	return max(s.nextFetch.Sub(s.now()), 0)
+}
+
+// fetchNow makes a fetch due straight away
+func (s *scheduler) fetchNow() {
+	s.nextFetch = time.Time{}
+}
+
+// fetched schedules the next fetch after one that produced statuses
+func (s *scheduler) fetched(statuses map[string]statusResult) {
+	s.nextFetch = s.now().Add(s.next(statuses))
+}
+
+// next returns the interval to wait after a fetch that produced statuses, counting it
+// towards the backoff if every watched region failed
+func (s *scheduler) next(statuses map[string]statusResult) time.Duration {
+	interval := time.Duration(0)
+	failed := true
+	for _, region := range s.policy.watched(currentConfig()) {
+		result, ok := statuses[region]
+		if !ok {
+			continue
+		}
+		if result.err == nil {
+			failed = false
+		}
+		if regionInterval := s.policy.regionInterval(result); interval == 0 || regionInterval < interval {
+			interval = regionInterval
+		}
+	}
+	if interval == 0 {
+		return s.policy.Active // Nothing fetched yet
+	}
+
+	if !failed {
+		s.failures = 0
+		return interval
+	}
+	s.failures++
+	backoff := s.policy.Active
+	for i := 1; i < s.failures && backoff < s.policy.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	return max(min(backoff, s.policy.MaxBackoff), s.policy.Active)
+}
+
+// pollFlags are command-line overrides for the poll policy
+type pollFlags struct {
+	active     *time.Duration
+	idle       *time.Duration
+	maxBackoff *time.Duration
+	display    *time.Duration
+}
+
+// addPollFlags registers the interval flags on fs. The display interval only applies to
+// watch mode, so it's only registered when display is true.
+func addPollFlags(fs *flag.FlagSet, display bool) pollFlags {
+	f := pollFlags{
+		active:     fs.Duration("active-interval", 0, "Fetch interval while a deployment is in progress (default 30s)"),
+		idle:       fs.Duration("idle-interval", 0, "Fetch interval once the deployment is complete (default 85s)"),
+		maxBackoff: fs.Duration("max-backoff", 0, "Longest fetch interval after repeated fetch errors (default 5m0s)"),
+	}
+	if display {
+		f.display = fs.Duration("display-interval", 0, "Longest time between display refreshes in watch mode (default 30s)")
+	}
+	return f
+}
+
+// apply overrides the active config's poll policy with any flags that were set
+func (f pollFlags) apply() error {
+	cfg := *currentConfig()
+	if err := f.override(&cfg.Poll); err != nil {
+		return err
+	}
+	applyConfig(&cfg)
+	return nil
+}
+
+// override sets the fields of policy whose flags were set, leaving policy unchanged if
+// the result isn't valid
+func (f pollFlags) override(policy *PollPolicy) error {
+	p := *policy
+	for _, override := range []struct {
+		flag  *time.Duration
+		field *time.Duration
+	}{
+		{f.active, &p.Active},
+		{f.idle, &p.Idle},
+		{f.maxBackoff, &p.MaxBackoff},
+		{f.display, &p.Display},
+	} {
+		if override.flag == nil || *override.flag == 0 {
+			continue
+		}
+		*override.field = *override.flag
+	}
+	if err := p.validate(); err != nil {
+		return err
+	}
+	*policy = p
+	return nil
+}
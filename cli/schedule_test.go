@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"testing"
+	"time"
+)
+
+// newTestScheduler returns a scheduler for policy driven by a fake clock
+func newTestScheduler(policy PollPolicy) (*scheduler, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)}
+	s := newScheduler(policy)
+	s.now = clock.Now
+	return s, clock
+}
+
+func TestScheduler_ActiveAndIdle(t *testing.T) {
+	s, clock := newTestScheduler(defaultPollPolicy)
+
+	if !s.due() {
+		t.Fatal("expected the first fetch to be due straight away")
+	}
+	s.fetched(testStatuses("overall", "testing", "au", "complete"))
+	if s.due() || s.until() != 30*time.Second {
+		t.Errorf("expected the next fetch in 30s while deploying, got %v", s.until())
+	}
+
+	clock.Advance(30 * time.Second)
+	if !s.due() {
+		t.Fatal("expected a fetch to be due after the active interval")
+	}
+	s.fetched(testStatuses("overall", "complete", "au", "testing"))
+	if s.until() != 85*time.Second {
+		t.Errorf("expected only the overall status to count by default, got %v", s.until())
+	}
+
+	clock.Advance(time.Minute)
+	s.fetchNow()
+	if !s.due() || s.until() != 0 {
+		t.Error("expected fetchNow to make a fetch due")
+	}
+}
+
+func TestScheduler_NothingFetched(t *testing.T) {
+	s, _ := newTestScheduler(defaultPollPolicy)
+	if got := s.next(nil); got != defaultPollPolicy.Active {
+		t.Errorf("expected the active interval before anything is fetched, got %v", got)
+	}
+}
+
+func TestScheduler_StatusPolicy(t *testing.T) {
+	policy := defaultPollPolicy
+	policy.Regions = []string{"overall", "au", "us"}
+	policy.Status = map[string]time.Duration{"deploy": 10 * time.Second, "pr": 2 * time.Minute}
+	s, _ := newTestScheduler(policy)
+
+	tests := []struct {
+		statuses map[string]statusResult
+		expected time.Duration
+	}{
+		{testStatuses("overall", "complete", "au", "complete", "us", "complete"), 85 * time.Second},
+		{testStatuses("overall", "testing", "au", "complete", "us", "complete"), 30 * time.Second},
+		{testStatuses("overall", "testing", "au", "Deploy", "us", "complete"), 10 * time.Second},
+		{testStatuses("overall", "pr", "au", "complete", "us", "complete"), 85 * time.Second},
+		{testStatuses("overall", "pr", "au", "testing", "us", "complete"), 30 * time.Second},
+		{testStatuses("overall", "complete", "eu", "deploy"), 85 * time.Second}, // eu isn't watched
+	}
+	for _, tt := range tests {
+		if got := s.next(tt.statuses); got != tt.expected {
+			t.Errorf("next(%v) = %v, expected %v", tt.statuses, got, tt.expected)
+		}
+	}
+}
+
+func TestScheduler_BacksOffOnRepeatedErrors(t *testing.T) {
+	policy := defaultPollPolicy
+	policy.Regions = []string{"overall", "au"}
+	policy.MaxBackoff = 3 * time.Minute
+	s, clock := newTestScheduler(policy)
+
+	failing := map[string]statusResult{
+		"overall": {region: "overall", err: errors.New("timeout")},
+		"au":      {region: "au", err: errors.New("timeout")},
+	}
+	var waits []time.Duration
+	for range 5 {
+		s.fetched(failing)
+		waits = append(waits, s.until())
+		clock.Advance(s.until())
+	}
+	expected := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute}
+	for i := range expected {
+		if waits[i] != expected[i] {
+			t.Fatalf("expected waits %v, got %v", expected, waits)
+		}
+	}
+
+	// One region answering is enough to stop backing off
+	partial := testStatuses("au", "complete")
+	partial["overall"] = failing["overall"]
+	if got := s.next(partial); got != 30*time.Second {
+		t.Errorf("expected the active interval while a region still fails, got %v", got)
+	}
+	if got := s.next(failing); got != 30*time.Second {
+		t.Errorf("expected the backoff to start again, got %v", got)
+	}
+}
+
+func TestPollPolicy_WatchedRegions(t *testing.T) {
+	if watched := defaultPollPolicy.watched(DefaultConfig()); len(watched) != 1 || watched[0] != "overall" {
+		t.Errorf("expected the overall region by default, got %v", watched)
+	}
+
+	cfg := &Config{Regions: []RegionConfig{
+		{ID: "au", URL: "https://example.com/au"},
+		{ID: "us", URL: "https://example.com/us"},
+	}}
+	if watched := defaultPollPolicy.watched(cfg); len(watched) != 2 {
+		t.Errorf("expected every region without an overall region, got %v", watched)
+	}
+}
+
+func TestPollFlags_Apply(t *testing.T) {
+	withConfig(t, func(cfg *Config) {
+		cfg.Poll = defaultPollPolicy
+		cfg.Poll.Idle = 2 * time.Minute // From the config file
+	})
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	f := addPollFlags(fs, true)
+	if err := fs.Parse([]string{"--active-interval", "15s", "--display-interval", "5s"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.apply(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p := currentConfig().Poll; p.Active != 15*time.Second || p.Display != 5*time.Second || p.Idle != 2*time.Minute {
+		t.Errorf("expected flags to override only what they set, got %+v", p)
+	}
+
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	f = addPollFlags(fs, false)
+	if fs.Lookup("display-interval") != nil {
+		t.Error("expected no display interval flag outside watch mode")
+	}
+	fs.Parse([]string{"--idle-interval", "-1s"})
+	if err := f.apply(); err == nil {
+		t.Error("expected an error for a negative interval")
+	}
+	if currentConfig().Poll.Idle != 2*time.Minute {
+		t.Error("expected an invalid override to leave the policy unchanged")
+	}
+}
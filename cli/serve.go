@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// dashboardHTML is the web dashboard served at /, which reads from /api/status
+//
+//go:embed dashboard/index.html
+var dashboardHTML []byte
+
+// serveShutdownTimeout is how long requests in progress get to finish when the server stops
+const serveShutdownTimeout = 10 * time.Second
+
+// server exposes the StatusCache over HTTP
+type server struct {
+	cache             *StatusCache
+	events            *eventBroker
+	changes           *cacheWatcher
+	heartbeatInterval time.Duration
+	mux               *http.ServeMux
+}
+
+// newServer creates a server reading from cache. Transitions recorded by cache, and any
+// change to it, are published to /api/events subscribers.
+func newServer(cache *StatusCache) *server {
+	s := &server{
+		cache:             cache,
+		events:            newEventBroker(cache.History()),
+		changes:           newCacheWatcher(cache),
+		heartbeatInterval: eventsHeartbeatInterval,
+		mux:               http.NewServeMux(),
+	}
+	cache.OnTransitions(s.events.publish)
+
+	s.mux.HandleFunc("GET /api/events", s.handleEvents)
+	s.mux.HandleFunc("GET /api/status", s.handleStatus)
+	s.mux.HandleFunc("GET /api/status/{region}", s.handleRegionStatus)
+	s.mux.HandleFunc("GET /healthz", s.handleHealth)
+	s.mux.HandleFunc("GET /metrics", s.handleMetrics)
+	s.mux.HandleFunc("GET /{$}", s.handleDashboard)
+	if secret := currentConfig().Slack.SigningSecret; secret != "" {
+		s.mux.Handle("POST /slack/command", newSlackHandler(cache, secret))
+	}
+	return s
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// writeJSON writes v as a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeJSONError writes an error response in the form {"error": "..."}
+func writeJSONError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, map[string]string{"error": message})
+}
+
+// handleStatus serves all configured regions, re-reading the cache so writes from
+// another fetcher process are visible
+func (s *server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	s.cache.Reload()
+	writeJSON(w, http.StatusOK, buildSnapshot(currentConfig(), s.cache))
+}
+
+// handleRegionStatus serves a single region
+func (s *server) handleRegionStatus(w http.ResponseWriter, r *http.Request) {
+	cfg := currentConfig()
+	region := r.PathValue("region")
+	if _, ok := cfg.Region(region); !ok {
+		writeJSONError(w, http.StatusNotFound, fmt.Sprintf("unknown region %q", region))
+		return
+	}
+
+	s.cache.Reload()
+	for _, entry := range buildSnapshot(cfg, s.cache).Regions {
+		if entry.ID == region {
+			writeJSON(w, http.StatusOK, entry)
+			return
+		}
+	}
+}
+
+// handleDashboard serves the embedded web dashboard
+func (s *server) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(dashboardHTML)
+}
+
+// handleHealth reports that the server is up
+func (s *server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// runServe implements the serve subcommand
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path to config file (default: user config dir)")
+	addr := fs.String("addr", ":8080", "Address to listen on")
+	textfile := fs.String("textfile", "", "Also write Prometheus metrics to this file for node_exporter")
+	intervals := addPollFlags(fs, false)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	cache, ok := initialize(*configPath)
+	if !ok {
+		return exitError
+	}
+	if err := intervals.apply(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+
+	// SIGINT and SIGTERM stop the server, cancelling event streams and any fetch in progress
+	ctx, stop := shutdownContext()
+	defer stop()
+
+	// Fetches share the lease with any watch processes, and only its holder notifies
+	f := newFetcher(cache)
+
+	// Deferred before the fetcher is waited for, so they're stopped after it
+	alerts, stopNotifiers, err := startNotifiers(cache, f.lease, "")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+	defer stopNotifiers()
+
+	reload, stopReload := notifyReload()
+	defer stopReload()
+
+	// Poll in the background
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		f.run(ctx, f.tick(ctx), fetchLoop{
+			reload:   reload,
+			onReload: configReloader(*configPath, intervals, f, alerts),
+			afterTick: func(bool, bool) {
+				if *textfile != "" {
+					if err := exportMetrics(cache, *textfile); err != nil {
+						fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					}
+				}
+			},
+		})
+	}()
+	defer func() { <-stopped }()
+
+	handler := newServer(cache)
+	go handler.events.run(ctx.Done())
+	go handler.events.forwardUpdates(handler.changes, ctx.Done())
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	fmt.Fprintf(os.Stderr, "Serving deploy status on %s\n", *addr)
+	if currentConfig().Slack.SigningSecret != "" {
+		fmt.Fprintln(os.Stderr, "Slack slash command enabled at /slack/command")
+	}
+
+	served := make(chan error, 1)
+	go func() { served <- srv.ListenAndServe() }()
+	select {
+	case err := <-served:
+		if !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			stop()
+			return exitError
+		}
+	case <-ctx.Done():
+		fmt.Fprintln(os.Stderr, "Shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), serveShutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+	}
+	return exitOK
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestServer(t *testing.T) (*server, *StatusCache) {
+	t.Helper()
+	cache := NewStatusCacheWithPath(filepath.Join(t.TempDir(), "statuses.json"))
+	cache.UpdateAll(map[string]statusResult{
+		"overall": {region: "overall", status: "testing"},
+		"au":      {region: "au", status: "complete"},
+		"ca":      {region: "ca", err: errors.New("HTTP 503"), attempts: 3},
+	})
+	return newServer(cache), cache
+}
+
+func TestServer_Status(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest("GET", "/api/status", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected JSON content type, got %q", ct)
+	}
+
+	var snapshot statusSnapshot
+	if err := json.Unmarshal(rec.Body.Bytes(), &snapshot); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if snapshot.SchemaVersion != snapshotSchemaVersion || len(snapshot.Regions) != len(currentConfig().Regions) {
+		t.Errorf("unexpected snapshot: %+v", snapshot)
+	}
+	if snapshot.Regions[0].Status != "testing" || snapshot.Regions[0].UpdatedAt == nil {
+		t.Errorf("unexpected overall entry: %+v", snapshot.Regions[0])
+	}
+}
+
+func TestServer_RegionStatus(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest("GET", "/api/status/ca", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+
+	var entry regionSnapshot
+	if err := json.Unmarshal(rec.Body.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if entry.ID != "ca" || entry.Error != "HTTP 503" || entry.Attempts != 3 || entry.Class != classRed {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+}
+
+func TestServer_UnknownRegion(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest("GET", "/api/status/eu", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", rec.Code)
+	}
+}
+
+func TestServer_ReloadsCacheWrittenByAnotherProcess(t *testing.T) {
+	srv, cache := newTestServer(t)
+
+	other := NewStatusCacheWithPath(cache.filePath)
+	other.UpdateAll(map[string]statusResult{"overall": {region: "overall", status: "complete"}})
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest("GET", "/api/status/overall", nil))
+
+	var entry regionSnapshot
+	json.Unmarshal(rec.Body.Bytes(), &entry)
+	if entry.Status != "complete" {
+		t.Errorf("expected status written by the other cache, got %q", entry.Status)
+	}
+}
+
+func TestServer_Health(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d", rec.Code)
+	}
+}
+
+func TestServer_RejectsOtherMethods(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest("POST", "/api/status", nil))
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", rec.Code)
+	}
+}
+
+func TestServer_Dashboard(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("expected HTML content type, got %q", ct)
+	}
+
+	body := rec.Body.String()
+	if !strings.Contains(body, "/api/status") {
+		t.Error("expected dashboard to read from the status API")
+	}
+	if !strings.Contains(body, "new EventSource(EVENTS_API)") || !strings.Contains(body, "'/api/events'") {
+		t.Error("expected dashboard to subscribe to transition events")
+	}
+	if strings.Contains(body, "content.fcsuite.com") {
+		t.Error("expected dashboard not to fetch status URLs directly")
+	}
+
+	// Only the root path serves the dashboard
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest("GET", "/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown path, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// shutdownSignals stop watch and serve mode cleanly
+var shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// shutdownContext returns a context cancelled by the first shutdown signal. Later signals
+// get their default behavior, so a second Ctrl+C exits straight away if shutting down hangs.
+func shutdownContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), shutdownSignals...)
+	context.AfterFunc(ctx, stop)
+	return ctx, stop
+}
+
+// shutdownGrace is how long webhook deliveries, hook runs and Slack posts queued before
+// shutdown get to finish
+const shutdownGrace = 10 * time.Second
+
+// graceContext returns a context that's done grace after ctx is, bounding how long work
+// queued before ctx was done gets to finish
+func graceContext(ctx context.Context, grace time.Duration) (context.Context, context.CancelFunc) {
+	graceCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() { time.AfterFunc(grace, cancel) })
+	return graceCtx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// startNotifiers starts the webhooks, hooks, Slack subscriptions and alerts for a watch or
+// serve process. The returned function stops them and waits for what's already queued, for
+// up to shutdownGrace; call it once fetching has stopped, so nothing is queued after they're
+// drained. The alert engine is returned for configReloader.
+func startNotifiers(cache *StatusCache, lease *Lease, onChange string) (*alertEngine, func(), error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	d, waitWebhooks, err := startWebhooks(ctx, cache, lease)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	waitHooks := startHooks(ctx, cache, lease, onChange)
+	slack, waitSlack := startSubscriptions(ctx, cache, lease)
+	alerts := startAlerts(cache, d, slack)
+	return alerts, func() {
+		cancel()
+		waitWebhooks()
+		waitHooks()
+		waitSlack()
+	}, nil
+}
+
+// notifyReload returns a channel that receives on SIGHUP, and a function to stop receiving.
+// Windows has no SIGHUP, so there it never receives.
+func notifyReload() (<-chan os.Signal, func()) {
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	return reload, func() { signal.Stop(reload) }
+}
+
+// configReloader returns a function that reloads the config file at path for a running
+// watch or serve process, keeping the interval flags. The fetcher's schedule and the alert
+// rules pick up the new config straight away; it runs between fetches, as fetchLoop.onReload.
+func configReloader(path string, intervals pollFlags, f *fetcher, alerts *alertEngine) func() {
+	return func() {
+		restart, err := reloadConfig(path, intervals)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reloading config: %v\n", err)
+			return
+		}
+		cfg := currentConfig()
+		f.schedule.policy = cfg.Poll
+		alerts.rules = cfg.Alerts
+		if len(restart) > 0 {
+			fmt.Fprintf(os.Stderr, "Config reloaded, restart to apply changes to %s\n", strings.Join(restart, ", "))
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// captureStderr returns what fn writes to stderr
+func captureStderr(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := os.Stderr
+	os.Stderr = w
+	defer func() { os.Stderr = original }()
+
+	out := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- string(data)
+	}()
+	fn()
+	w.Close()
+	return <-out
+}
+
+func TestGraceContext_DoneGraceAfterParent(t *testing.T) {
+	const grace = 100 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	graceCtx, stop := graceContext(ctx, grace)
+	defer stop()
+
+	time.Sleep(2 * grace)
+	if graceCtx.Err() != nil {
+		t.Fatal("expected the grace context to last while the parent does")
+	}
+
+	cancel()
+	cancelled := time.Now()
+	if graceCtx.Err() != nil {
+		t.Fatal("expected the grace context to outlast the parent")
+	}
+	select {
+	case <-graceCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("expected the grace context to be done after the grace period")
+	}
+	if elapsed := time.Since(cancelled); elapsed < grace {
+		t.Errorf("expected the grace context to last %s after the parent, got %s", grace, elapsed)
+	}
+}
+
+func TestGraceContext_StopCancelsImmediately(t *testing.T) {
+	graceCtx, stop := graceContext(context.Background(), time.Hour)
+	stop()
+	if graceCtx.Err() == nil {
+		t.Error("expected stop to cancel the grace context")
+	}
+}
+
+func TestConfigReloader_AppliesPollAndAlerts(t *testing.T) {
+	withConfig(t, func(cfg *Config) {})
+	path := filepath.Join(t.TempDir(), "config.toml")
+	os.WriteFile(path, []byte(`
+[poll]
+active = "5s"
+idle = "3m"
+
+[[alerts]]
+name = "tests-failed"
+status = ["testfail"]
+`), 0644)
+
+	cache := NewStatusCacheWithPath(filepath.Join(t.TempDir(), "statuses.json"))
+	f := newFetcher(cache)
+	alerts := newAlertEngine(nil, alertsPath(cache))
+
+	stderr := captureStderr(t, configReloader(path, pollFlags{}, f, alerts))
+
+	if f.schedule.policy.Active != 5*time.Second || f.schedule.policy.Idle != 3*time.Minute {
+		t.Errorf("expected the fetcher to use the new poll intervals, got %+v", f.schedule.policy)
+	}
+	if len(alerts.rules) != 1 || alerts.rules[0].Name != "tests-failed" {
+		t.Errorf("expected the alert engine to use the new rules, got %+v", alerts.rules)
+	}
+	if stderr != "" {
+		t.Errorf("expected no restart notice, got %q", stderr)
+	}
+}
+
+func TestConfigReloader_KeepsStartupNotifiers(t *testing.T) {
+	withConfig(t, func(cfg *Config) {
+		cfg.Webhooks = []WebhookConfig{{URL: "https://example.com/a"}}
+		cfg.On = map[string]string{"complete": "echo a"}
+		cfg.Slack.WebhookURL = "https://hooks.slack.com/services/A"
+	})
+	path := filepath.Join(t.TempDir(), "config.toml")
+	os.WriteFile(path, []byte(`
+[slack]
+webhook_url = "https://hooks.slack.com/services/B"
+
+[[webhooks]]
+url = "https://example.com/b"
+
+[on]
+complete = "echo b"
+`), 0644)
+
+	cache := NewStatusCacheWithPath(filepath.Join(t.TempDir(), "statuses.json"))
+	stderr := captureStderr(t, configReloader(path, pollFlags{}, newFetcher(cache), newAlertEngine(nil, alertsPath(cache))))
+
+	cfg := currentConfig()
+	if cfg.Webhooks[0].URL != "https://example.com/a" {
+		t.Errorf("expected webhooks to keep their startup value, got %+v", cfg.Webhooks)
+	}
+	if !reflect.DeepEqual(cfg.On, map[string]string{"complete": "echo a"}) {
+		t.Errorf("expected hooks to keep their startup value, got %v", cfg.On)
+	}
+	if cfg.Slack.WebhookURL != "https://hooks.slack.com/services/A" {
+		t.Errorf("expected Slack to keep its startup value, got %+v", cfg.Slack)
+	}
+	if !strings.Contains(stderr, "restart to apply changes to webhooks, hooks, slack") {
+		t.Errorf("expected a restart notice for webhooks, hooks and slack, got %q", stderr)
+	}
+}
+
+func TestStartNotifiers_DrainsQueuedDeliveries(t *testing.T) {
+	var mu sync.Mutex
+	received := make(map[string]int)
+	originalClient := httpClient
+	defer func() { httpClient = originalClient }()
+	httpClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		time.Sleep(20 * time.Millisecond) // Slow enough for deliveries to still be queued
+		mu.Lock()
+		received[req.URL.String()]++
+		mu.Unlock()
+		return (&mockTransport{responses: map[string]mockResponse{req.URL.String(): {body: "ok"}}}).RoundTrip(req)
+	})}
+	withConfig(t, func(cfg *Config) {
+		cfg.Webhooks = []WebhookConfig{{Name: "test", URL: "https://example.com/hook"}}
+	})
+
+	dir := t.TempDir()
+	cache := NewStatusCacheWithPath(filepath.Join(dir, "statuses.json"))
+	cache.Subscriptions().Add(subscription{Channel: "#deploys", WebhookURL: "https://hooks.slack.com/services/A"})
+	lease := NewLease(filepath.Join(dir, "fetcher.lease"), leaseTTL)
+	lease.TryAcquire()
+
+	_, stop, err := startNotifiers(cache, lease, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	statuses := []string{"testing", "testok", "merging", "deploy", "complete"}
+	for _, status := range statuses {
+		cache.UpdateAll(map[string]statusResult{"au": {region: "au", status: status}})
+	}
+	stop()
+
+	// Every change but the first observation was queued before stopping
+	mu.Lock()
+	defer mu.Unlock()
+	for _, url := range []string{"https://example.com/hook", "https://hooks.slack.com/services/A"} {
+		if received[url] != len(statuses)-1 {
+			t.Errorf("expected %d deliveries to %s after stopping, got %d", len(statuses)-1, url, received[url])
+		}
+	}
+}
+
+func TestRunWatch_StopsAndReleasesLease(t *testing.T) {
+	responses := make(map[string]mockResponse)
+	for _, r := range currentConfig().Regions {
+		responses[r.URL] = mockResponse{body: "complete"}
+	}
+	originalClient := httpClient
+	defer func() { httpClient = originalClient }()
+	httpClient = &http.Client{Transport: &mockTransport{responses: responses}}
+
+	cache := NewStatusCacheWithPath(filepath.Join(t.TempDir(), "statuses.json"))
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		// Stop after the first fetch, or give up waiting for it
+		for deadline := time.Now().Add(time.Second); cache.GetLastFetchedAt().IsZero() && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		}
+		cancel()
+	}()
+
+	var code int
+	output := captureStdout(t, func() {
+		code = runWatch(ctx, cache, watchOptions{output: outputNDJSON})
+	})
+
+	if code != exitOK {
+		t.Errorf("expected exit code %d, got %d", exitOK, code)
+	}
+	if !strings.Contains(output, `"status":"complete"`) {
+		t.Errorf("expected the fetched statuses to be shown, got %q", output)
+	}
+	if held, _ := NewLease(filepath.Join(filepath.Dir(cache.filePath), "fetcher.lease"), leaseTTL).TryAcquire(); !held {
+		t.Error("expected the lease to be released for another process")
+	}
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/user"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// defaultAckDuration is how long an acknowledgement lasts without --for
+const defaultAckDuration = time.Hour
+
+// silence suppresses notifications until it expires. A silence without an alert is an
+// acknowledgement of its region: nothing is sent about the region, and it doesn't count
+// towards exit codes. A silence with an alert only suppresses that alert rule, for one
+// region or all of them.
+type silence struct {
+	ID        string    `json:"id"`
+	Region    string    `json:"region,omitempty"`
+	Alert     string    `json:"alert,omitempty"`
+	Note      string    `json:"note,omitempty"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// matches reports whether s silences notifications about region. With alert set, it
+// matches the notifications of that alert rule, otherwise status transitions.
+func (s silence) matches(region, alert string) bool {
+	return (s.Region == "" || s.Region == region) && (s.Alert == "" || s.Alert == alert)
+}
+
+// SilenceStore persists silences as a JSON file next to the status cache. Expired
+// silences are ignored, and dropped the next time the file is written.
+type SilenceStore struct {
+	mu   sync.Mutex
+	path string
+	now  func() time.Time
+}
+
+// NewSilenceStore creates a SilenceStore reading and writing path
+func NewSilenceStore(path string) *SilenceStore {
+	return &SilenceStore{path: path, now: time.Now}
+}
+
+// list reads the stored silences, including expired ones. Callers must hold s.mu and the
+// file lock.
+func (s *SilenceStore) list() ([]silence, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read silences: %w", err)
+	}
+
+	var silences []silence
+	if err := json.Unmarshal(data, &silences); err != nil {
+		return nil, fmt.Errorf("failed to parse silences: %w", err)
+	}
+	return silences, nil
+}
+
+// update applies fn to the unexpired silences under the store's locks and saves the result
+func (s *SilenceStore) update(fn func([]silence) []silence) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return withFileLock(s.path+".lock", func() error {
+		silences, err := s.list()
+		if err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(fn(s.unexpired(silences)), "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal silences: %w", err)
+		}
+		if err := writeFileAtomic(s.path, data, 0644); err != nil {
+			return fmt.Errorf("failed to write silences: %w", err)
+		}
+		return nil
+	})
+}
+
+// unexpired returns the silences that haven't expired yet
+func (s *SilenceStore) unexpired(silences []silence) []silence {
+	now := s.now()
+	active := make([]silence, 0, len(silences))
+	for _, existing := range silences {
+		if now.Before(existing.ExpiresAt) {
+			active = append(active, existing)
+		}
+	}
+	return active
+}
+
+// Active returns the silences that haven't expired, soonest to expire first
+func (s *SilenceStore) Active() ([]silence, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var silences []silence
+	err := withFileLock(s.path+".lock", func() error {
+		var err error
+		silences, err = s.list()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	active := s.unexpired(silences)
+	sort.SliceStable(active, func(i, j int) bool { return active[i].ExpiresAt.Before(active[j].ExpiresAt) })
+	return active, nil
+}
+
+// Add stores sil with a new ID, replacing any silence for the same region and alert
+func (s *SilenceStore) Add(sil silence) (silence, error) {
+	id := make([]byte, 3)
+	rand.Read(id)
+	sil.ID = hex.EncodeToString(id)
+
+	err := s.update(func(silences []silence) []silence {
+		kept := make([]silence, 0, len(silences)+1)
+		for _, existing := range silences {
+			if existing.Region != sil.Region || existing.Alert != sil.Alert {
+				kept = append(kept, existing)
+			}
+		}
+		return append(kept, sil)
+	})
+	return sil, err
+}
+
+// Remove deletes the silence with ID target, or every silence for the region target,
+// returning how many were removed
+func (s *SilenceStore) Remove(target string) (int, error) {
+	removed := 0
+	err := s.update(func(silences []silence) []silence {
+		kept := make([]silence, 0, len(silences))
+		for _, existing := range silences {
+			if existing.ID == target || existing.Region == target {
+				removed++
+				continue
+			}
+			kept = append(kept, existing)
+		}
+		return kept
+	})
+	return removed, err
+}
+
+// Silenced reports whether notifications about region are silenced: status transitions if
+// alert is empty, otherwise that alert rule's notifications. If the silences can't be
+// read, nothing is silenced.
+func (s *SilenceStore) Silenced(region, alert string) bool {
+	silences, err := s.Active()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return false
+	}
+	for _, sil := range silences {
+		if sil.matches(region, alert) {
+			return true
+		}
+	}
+	return false
+}
+
+// Acknowledged returns the active acknowledgement of each acknowledged region, the one
+// lasting longest if there are several
+func (s *SilenceStore) Acknowledged() (map[string]silence, error) {
+	silences, err := s.Active()
+	if err != nil {
+		return nil, err
+	}
+	acks := make(map[string]silence)
+	for _, sil := range silences {
+		if sil.Alert == "" {
+			acks[sil.Region] = sil // Ordered by expiry, so the longest lasting wins
+		}
+	}
+	return acks, nil
+}
+
+// filterTransitions wraps fn so it's only called with transitions of regions that aren't
+// silenced
+func (s *SilenceStore) filterTransitions(fn func([]transition)) func([]transition) {
+	return func(entries []transition) {
+		var kept []transition
+		for _, entry := range entries {
+			if !s.Silenced(entry.Region, "") {
+				kept = append(kept, entry)
+			}
+		}
+		if len(kept) > 0 {
+			fn(kept)
+		}
+	}
+}
+
+// filterAlerts wraps fn so it's only called for alerts that aren't silenced
+func (s *SilenceStore) filterAlerts(fn func(alertEvent)) func(alertEvent) {
+	return func(ev alertEvent) {
+		if !s.Silenced(ev.Region, ev.Rule) {
+			fn(ev)
+		}
+	}
+}
+
+// acknowledgedRegions returns the regions acknowledged in cache's silences. Acknowledgements
+// are extra, so if they can't be read no region is acknowledged.
+func acknowledgedRegions(cache *StatusCache) map[string]silence {
+	acks, err := cache.Silences().Acknowledged()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	}
+	return acks
+}
+
+// formatAck describes an acknowledgement for the status display, e.g.
+// "acked by alice until 15:30: fixing flaky test"
+func formatAck(ack silence, now time.Time) string {
+	text := "acked"
+	if ack.CreatedBy != "" {
+		text += " by " + ack.CreatedBy
+	}
+	text += " until " + formatExpiry(ack.ExpiresAt, now)
+	if ack.Note != "" {
+		text += ": " + ack.Note
+	}
+	return text
+}
+
+// currentUser returns the name silences are recorded as created by
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// formatExpiry formats when a silence expires, with the date only if it isn't today
+func formatExpiry(expires, now time.Time) string {
+	expires, now = expires.Local(), now.Local()
+	if y, m, d := expires.Date(); y == now.Year() && m == now.Month() && d == now.Day() {
+		return expires.Format("15:04")
+	}
+	return expires.Format("Jan 2 15:04")
+}
+
+// printSilences writes a table of silences to w
+func printSilences(w io.Writer, silences []silence, now time.Time) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tREGION\tALERT\tUNTIL\tBY\tNOTE")
+	for _, sil := range silences {
+		region, alert := sil.Region, sil.Alert
+		if region == "" {
+			region = "all"
+		}
+		if alert == "" {
+			alert = "all"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", sil.ID, region, alert, formatExpiry(sil.ExpiresAt, now), sil.CreatedBy, sil.Note)
+	}
+	tw.Flush()
+}
+
+// parseInterspersed parses args with fs, allowing flags after positional arguments, and
+// returns the positional arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// runAck implements the ack subcommand
+func runAck(args []string) int {
+	fs := flag.NewFlagSet("ack", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: deploy-status ack <region> [--for 30m] [--note text]")
+		fmt.Fprintln(fs.Output(), "       deploy-status ack --alert <rule> [<region>] [--for 30m] [--note text]")
+		fmt.Fprintln(fs.Output(), "       deploy-status ack --list")
+		fmt.Fprintln(fs.Output(), "       deploy-status ack --remove <id or region>")
+		fs.PrintDefaults()
+	}
+	configPath := fs.String("config", "", "Path to config file (default: user config dir)")
+	duration := fs.Duration("for", defaultAckDuration, "How long the silence lasts")
+	note := fs.String("note", "", "Why the region or alert is silenced, shown in the status display")
+	alert := fs.String("alert", "", "Only silence this alert rule, in one region or all of them")
+	list := fs.Bool("list", false, "List active silences")
+	remove := fs.String("remove", "", "Remove the silence with this ID, or every silence for this region")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+
+	cache, ok := initialize(*configPath)
+	if !ok {
+		return exitError
+	}
+	store := cache.Silences()
+	now := time.Now()
+
+	switch {
+	case *list:
+		silences, err := store.Active()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitError
+		}
+		if len(silences) == 0 {
+			fmt.Println("No active silences")
+			return exitOK
+		}
+		printSilences(os.Stdout, silences, now)
+		return exitOK
+
+	case *remove != "":
+		removed, err := store.Remove(strings.ToLower(*remove))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return exitError
+		}
+		if removed == 0 {
+			fmt.Fprintf(os.Stderr, "Error: no silence matches %q\n", *remove)
+			return exitError
+		}
+		fmt.Printf("Removed %d silence(s)\n", removed)
+		return exitOK
+	}
+
+	if len(positional) > 1 || (len(positional) == 0 && *alert == "") {
+		fs.Usage()
+		return exitUsage
+	}
+	sil := silence{
+		Alert:     *alert,
+		Note:      *note,
+		CreatedBy: currentUser(),
+		CreatedAt: now,
+		ExpiresAt: now.Add(*duration),
+	}
+	if len(positional) == 1 {
+		sil.Region = strings.ToLower(positional[0])
+		cfg := currentConfig()
+		if _, ok := cfg.Region(sil.Region); !ok {
+			fmt.Fprintf(os.Stderr, "Error: unknown region %q (expected one of %s)\n", sil.Region, strings.Join(cfg.RegionIDs(), ", "))
+			return exitUsage
+		}
+	}
+	if *duration <= 0 {
+		fmt.Fprintln(os.Stderr, "Error: --for must be positive")
+		return exitUsage
+	}
+	if sil.Alert != "" && !alertRuleExists(sil.Alert) {
+		fmt.Fprintf(os.Stderr, "Error: unknown alert %q\n", sil.Alert)
+		return exitUsage
+	}
+
+	sil, err = store.Add(sil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+	var what string
+	switch {
+	case sil.Alert == "":
+		what = "Acknowledged " + regionLabel(sil.Region)
+	case sil.Region != "":
+		what = fmt.Sprintf("Silenced alert %s for %s", sil.Alert, regionLabel(sil.Region))
+	default:
+		what = "Silenced alert " + sil.Alert
+	}
+	fmt.Printf("%s until %s (id %s)\n", what, formatExpiry(sil.ExpiresAt, now), sil.ID)
+	return exitOK
+}
+
+// alertRuleExists reports whether an alert rule is configured with name
+func alertRuleExists(name string) bool {
+	for _, rule := range currentConfig().Alerts {
+		if rule.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestSilenceStore creates a store on a fake clock
+func newTestSilenceStore(t *testing.T) (*SilenceStore, *fakeClock) {
+	t.Helper()
+	clock := &fakeClock{t: time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)}
+	store := NewSilenceStore(filepath.Join(t.TempDir(), "silences.json"))
+	store.now = clock.Now
+	return store, clock
+}
+
+func TestSilenceStore_ExpiresSilences(t *testing.T) {
+	store, clock := newTestSilenceStore(t)
+	store.Add(silence{Region: "au", ExpiresAt: clock.Now().Add(30 * time.Minute)})
+	store.Add(silence{Region: "us", ExpiresAt: clock.Now().Add(10 * time.Minute)})
+
+	active, err := store.Active()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(active) != 2 || active[0].Region != "us" || active[0].ID == "" {
+		t.Fatalf("expected both silences, soonest to expire first, got %+v", active)
+	}
+
+	clock.Advance(10 * time.Minute)
+	if active, _ := store.Active(); len(active) != 1 || active[0].Region != "au" {
+		t.Errorf("expected the us silence to have expired, got %+v", active)
+	}
+	if store.Silenced("us", "") || !store.Silenced("au", "") {
+		t.Error("expected only au to be silenced")
+	}
+}
+
+func TestSilenceStore_AddReplacesSameTarget(t *testing.T) {
+	store, clock := newTestSilenceStore(t)
+	store.Add(silence{Region: "au", Note: "first", ExpiresAt: clock.Now().Add(time.Hour)})
+	store.Add(silence{Region: "au", Alert: "tests-failed", ExpiresAt: clock.Now().Add(time.Hour)})
+	store.Add(silence{Region: "au", Note: "second", ExpiresAt: clock.Now().Add(time.Hour)})
+
+	active, _ := store.Active()
+	if len(active) != 2 {
+		t.Fatalf("expected the au acknowledgement to be replaced, got %+v", active)
+	}
+	acks, _ := store.Acknowledged()
+	if len(acks) != 1 || acks["au"].Note != "second" {
+		t.Errorf("expected the second acknowledgement, got %+v", acks)
+	}
+}
+
+func TestSilenceStore_Remove(t *testing.T) {
+	store, clock := newTestSilenceStore(t)
+	kept, _ := store.Add(silence{Region: "us", ExpiresAt: clock.Now().Add(time.Hour)})
+	store.Add(silence{Region: "au", ExpiresAt: clock.Now().Add(time.Hour)})
+	store.Add(silence{Region: "au", Alert: "tests-failed", ExpiresAt: clock.Now().Add(time.Hour)})
+
+	if removed, err := store.Remove("au"); err != nil || removed != 2 {
+		t.Errorf("expected both au silences removed, got %d, %v", removed, err)
+	}
+	if removed, err := store.Remove(kept.ID); err != nil || removed != 1 {
+		t.Errorf("expected the us silence removed by ID, got %d, %v", removed, err)
+	}
+	if removed, _ := store.Remove("ca"); removed != 0 {
+		t.Errorf("expected nothing removed for ca, got %d", removed)
+	}
+}
+
+func TestSilence_Matches(t *testing.T) {
+	ack := silence{Region: "au"}
+	alertInRegion := silence{Region: "au", Alert: "tests-failed"}
+	alertEverywhere := silence{Alert: "fetch-failing"}
+
+	tests := []struct {
+		name          string
+		sil           silence
+		region, alert string
+		expected      bool
+	}{
+		{"ack silences transitions", ack, "au", "", true},
+		{"ack silences every alert", ack, "au", "deploy-stuck", true},
+		{"ack is per region", ack, "us", "", false},
+		{"alert silence keeps transitions", alertInRegion, "au", "", false},
+		{"alert silence matches its alert", alertInRegion, "au", "tests-failed", true},
+		{"alert silence is per region", alertInRegion, "us", "tests-failed", false},
+		{"alert silence keeps other alerts", alertInRegion, "au", "deploy-stuck", false},
+		{"alert silence for every region", alertEverywhere, "us", "fetch-failing", true},
+	}
+	for _, tt := range tests {
+		if got := tt.sil.matches(tt.region, tt.alert); got != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestSilenceStore_Acknowledged(t *testing.T) {
+	store, clock := newTestSilenceStore(t)
+	store.Add(silence{Region: "au", Note: "fixing flaky test", ExpiresAt: clock.Now().Add(time.Hour)})
+	store.Add(silence{Region: "us", Alert: "tests-failed", ExpiresAt: clock.Now().Add(time.Hour)})
+
+	acks, err := store.Acknowledged()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(acks) != 1 || acks["au"].Note != "fixing flaky test" {
+		t.Errorf("expected only au to be acknowledged, got %+v", acks)
+	}
+}
+
+func TestSilenceStore_Filters(t *testing.T) {
+	store, clock := newTestSilenceStore(t)
+	store.Add(silence{Region: "au", ExpiresAt: clock.Now().Add(time.Hour)})
+	store.Add(silence{Alert: "fetch-failing", ExpiresAt: clock.Now().Add(time.Hour)})
+
+	var transitions []transition
+	store.filterTransitions(func(entries []transition) { transitions = append(transitions, entries...) })([]transition{
+		{Region: "au", From: "testing", To: "testfail"},
+		{Region: "us", From: "testing", To: "testfail"},
+	})
+	if len(transitions) != 1 || transitions[0].Region != "us" {
+		t.Errorf("expected only the us transition, got %+v", transitions)
+	}
+
+	var alerts []string
+	notify := store.filterAlerts(func(ev alertEvent) { alerts = append(alerts, ev.Rule+"/"+ev.Region) })
+	notify(alertEvent{Rule: "tests-failed", Region: "au"})
+	notify(alertEvent{Rule: "tests-failed", Region: "us"})
+	notify(alertEvent{Rule: "fetch-failing", Region: "us"})
+	if strings.Join(alerts, ",") != "tests-failed/us" {
+		t.Errorf("expected only tests-failed/us, got %v", alerts)
+	}
+}
+
+func TestFormatAck(t *testing.T) {
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.Local)
+	ack := silence{CreatedBy: "alice", Note: "fixing flaky test", ExpiresAt: now.Add(30 * time.Minute)}
+	if got := formatAck(ack, now); got != "acked by alice until 09:30: fixing flaky test" {
+		t.Errorf("unexpected text %q", got)
+	}
+
+	ack = silence{ExpiresAt: now.Add(48 * time.Hour)}
+	if got := formatAck(ack, now); got != "acked until Oct 18 09:00" {
+		t.Errorf("unexpected text %q", got)
+	}
+}
+
+func TestPrintSilences(t *testing.T) {
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.Local)
+	var buf bytes.Buffer
+	printSilences(&buf, []silence{
+		{ID: "a1b2c3", Region: "au", CreatedBy: "alice", Note: "fixing flaky test", ExpiresAt: now.Add(30 * time.Minute)},
+		{ID: "d4e5f6", Alert: "fetch-failing", ExpiresAt: now.Add(time.Hour)},
+	}, now)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected a header and 2 rows, got:\n%s", buf.String())
+	}
+	if fields := strings.Fields(lines[1]); strings.Join(fields, " ") != "a1b2c3 au all 09:30 alice fixing flaky test" {
+		t.Errorf("unexpected row %q", lines[1])
+	}
+	if fields := strings.Fields(lines[2]); strings.Join(fields, " ") != "d4e5f6 all fetch-failing 10:00" {
+		t.Errorf("unexpected row %q", lines[2])
+	}
+}
+
+func TestParseInterspersed(t *testing.T) {
+	fs := flag.NewFlagSet("ack", flag.ContinueOnError)
+	duration := fs.Duration("for", time.Hour, "")
+	note := fs.String("note", "", "")
+
+	positional, err := parseInterspersed(fs, []string{"au", "--for", "30m", "--note", "fixing flaky test"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(positional) != 1 || positional[0] != "au" || *duration != 30*time.Minute || *note != "fixing flaky test" {
+		t.Errorf("unexpected parse: %v, %v, %q", positional, *duration, *note)
+	}
+}
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SlackConfig configures the Slack slash command served by serve
+type SlackConfig struct {
+	// SigningSecret verifies requests from Slack. The slash command is only served when set.
+	// The SLACK_SIGNING_SECRET environment variable overrides it.
+	SigningSecret string `toml:"signing_secret"`
+	// WebhookURL is the incoming webhook used by `subscribe #channel` when no URL is given.
+	// Slack posts to the channel the webhook was created for, whatever channel is named.
+	WebhookURL string `toml:"webhook_url"`
+}
+
+// Limits for incoming slash command requests
+const (
+	slackMaxSkew      = 5 * time.Minute // oldest request timestamp accepted, to stop replays
+	slackMaxBodyBytes = 64 << 10
+)
+
+// slackClassEmoji is the emoji shown for each status color class
+var slackClassEmoji = map[string]string{
+	classRed:   "🟥",
+	classGreen: "🟩",
+	classBlue:  "🟦",
+	classWhite: "⬜",
+}
+
+// Block Kit message types used in slash command responses
+type (
+	slackMessage struct {
+		ResponseType string       `json:"response_type,omitempty"`
+		Text         string       `json:"text"` // shown in notifications and by clients without Block Kit
+		Blocks       []slackBlock `json:"blocks"`
+	}
+	slackBlock struct {
+		Type     string      `json:"type"`
+		Text     *slackText  `json:"text,omitempty"`
+		Elements []slackText `json:"elements,omitempty"`
+	}
+	slackText struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+)
+
+// verifySlackSignature checks a request's X-Slack-Signature against the signing secret,
+// rejecting requests whose timestamp is more than slackMaxSkew from now
+func verifySlackSignature(secret string, header http.Header, body []byte, now time.Time) error {
+	timestamp := header.Get("X-Slack-Request-Timestamp")
+	signature := header.Get("X-Slack-Signature")
+	if timestamp == "" || signature == "" {
+		return errors.New("missing signature headers")
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp %q", timestamp)
+	}
+	if skew := now.Sub(time.Unix(seconds, 0)); skew > slackMaxSkew || skew < -slackMaxSkew {
+		return errors.New("timestamp too far from current time")
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(slackSignature(secret, timestamp, body))) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// slackSignature computes the v0 signature Slack sends for a request body
+func slackSignature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:", timestamp)
+	mac.Write(body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// slackRegionLine formats a region's entry as "<emoji> *Label:* status"
+func slackRegionLine(entry regionSnapshot) string {
+	value := entry.Status
+	if entry.Error != "" {
+		value = entry.Error
+		if entry.Attempts > 1 {
+			value = fmt.Sprintf("%s (after %d attempts)", value, entry.Attempts)
+		}
+	}
+	return fmt.Sprintf("%s *%s:* %s", slackClassEmoji[entry.Class], entry.Label, value)
+}
+
+// renderSlackStatus formats a snapshot as an ephemeral Block Kit message
+func renderSlackStatus(snapshot statusSnapshot) slackMessage {
+	lines := make([]string, 0, len(snapshot.Regions))
+	var updatedAt time.Time
+	for _, entry := range snapshot.Regions {
+		lines = append(lines, slackRegionLine(entry))
+		if entry.UpdatedAt != nil && entry.UpdatedAt.After(updatedAt) {
+			updatedAt = *entry.UpdatedAt
+		}
+	}
+
+	if len(lines) == 0 {
+		return slackReply("No statuses have been fetched yet")
+	}
+
+	msg := slackMessage{ResponseType: "ephemeral", Text: lines[0]}
+	msg.Blocks = []slackBlock{{Type: "section", Text: &slackText{Type: "mrkdwn", Text: strings.Join(lines, "\n")}}}
+	if !updatedAt.IsZero() {
+		msg.Blocks = append(msg.Blocks, slackBlock{
+			Type:     "context",
+			Elements: []slackText{{Type: "mrkdwn", Text: "Last changed " + slackDate(updatedAt)}},
+		})
+	}
+	return msg
+}
+
+// slackReply returns an ephemeral message containing text
+func slackReply(text string) slackMessage {
+	return slackMessage{
+		ResponseType: "ephemeral",
+		Text:         text,
+		Blocks:       []slackBlock{{Type: "section", Text: &slackText{Type: "mrkdwn", Text: text}}},
+	}
+}
+
+// slackDate formats t for Slack to render in each reader's own time zone
+func slackDate(t time.Time) string {
+	return fmt.Sprintf("<!date^%d^{date_short_pretty} {time}|%s>", t.Unix(), t.UTC().Format(time.RFC1123))
+}
+
+// slackTransitionLine formats a transition as "<emoji> *Label:* from → to"
+func slackTransitionLine(entry transition) string {
+	label := regionLabel(entry.Region)
+	from := entry.From
+	if from == "" {
+		from = "(first seen)"
+	}
+	return fmt.Sprintf("%s *%s:* %s → %s", slackClassEmoji[classifyStatus(entry.To)], label, from, entry.To)
+}
+
+// renderSlackHistory formats transitions, oldest first, as an ephemeral message
+func renderSlackHistory(entries []transition) slackMessage {
+	if len(entries) == 0 {
+		return slackReply("No status transitions recorded")
+	}
+	lines := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		lines = append(lines, fmt.Sprintf("%s  %s", slackDate(entry.ChangedAt), slackTransitionLine(entry)))
+	}
+	return slackReply(strings.Join(lines, "\n"))
+}
+
+// renderSlackTransition formats a transition as a channel notification
+func renderSlackTransition(entry transition) slackMessage {
+	line := slackTransitionLine(entry)
+	return slackMessage{
+		Text: line,
+		Blocks: []slackBlock{
+			{Type: "section", Text: &slackText{Type: "mrkdwn", Text: line}},
+			{Type: "context", Elements: []slackText{{Type: "mrkdwn", Text: slackDate(entry.ChangedAt)}}},
+		},
+	}
+}
+
+// renderSlackAlert formats an alert firing or resolving as a channel notification
+func renderSlackAlert(ev alertEvent) slackMessage {
+	emoji := "🚨"
+	if ev.State == alertResolved {
+		emoji = "✅"
+	}
+	line := fmt.Sprintf("%s *%s* %s: %s", emoji, ev.Rule, ev.State, ev.summary())
+	return slackMessage{
+		Text: line,
+		Blocks: []slackBlock{
+			{Type: "section", Text: &slackText{Type: "mrkdwn", Text: line}},
+			{Type: "context", Elements: []slackText{{Type: "mrkdwn", Text: slackDate(ev.At)}}},
+		},
+	}
+}
+
+// slackHandler answers the deploy status slash command from the cache and manages
+// channel subscriptions
+type slackHandler struct {
+	cache  *StatusCache
+	secret string
+	now    func() time.Time
+}
+
+// newSlackHandler creates a slash command handler verifying requests with secret
+func newSlackHandler(cache *StatusCache, secret string) *slackHandler {
+	return &slackHandler{cache: cache, secret: secret, now: time.Now}
+}
+
+func (h *slackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, slackMaxBodyBytes))
+	if err != nil {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err := verifySlackSignature(h.secret, r.Header, body, h.now()); err != nil {
+		http.Error(w, "invalid request signature: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "invalid form body", http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.respond(form))
+}
+
+// respond runs the slash command described by a request's form fields:
+//
+//	/deploy-status [region...]
+//	/deploy-status history [N]
+//	/deploy-status subscribe #channel [webhook URL]
+//	/deploy-status unsubscribe #channel
+//	/deploy-status subscriptions
+func (h *slackHandler) respond(form url.Values) slackMessage {
+	cfg := currentConfig()
+	args := strings.Fields(form.Get("text"))
+	if len(args) == 0 {
+		h.cache.Reload()
+		return renderSlackStatus(buildSnapshot(cfg, h.cache))
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "help":
+		return slackReply(slackUsage(form.Get("command")))
+	case "history":
+		return h.history(args[1:])
+	case "subscribe":
+		return h.subscribe(args[1:], form.Get("user_name"))
+	case "unsubscribe":
+		return h.unsubscribe(args[1:])
+	case "subscriptions":
+		return h.listSubscriptions()
+	}
+
+	selected, err := parseRegions(cfg, strings.Join(args, ","))
+	if err != nil {
+		return slackReply(fmt.Sprintf("%s\n\n%s", err, slackUsage(form.Get("command"))))
+	}
+	h.cache.Reload()
+	return renderSlackStatus(filterSnapshot(buildSnapshot(cfg, h.cache), selected))
+}
+
+// slackUsage describes the slash command's arguments
+func slackUsage(command string) string {
+	if command == "" {
+		command = "/deploy-status"
+	}
+	return strings.Join([]string{
+		fmt.Sprintf("`%s` show every region", command),
+		fmt.Sprintf("`%s au ca` show selected regions", command),
+		fmt.Sprintf("`%s history [N]` show the last N status changes (default %d)", command, slackHistoryDefault),
+		fmt.Sprintf("`%s subscribe #channel [webhook URL]` post status changes through the channel's incoming webhook", command),
+		fmt.Sprintf("`%s unsubscribe #channel` stop posting to a channel", command),
+		fmt.Sprintf("`%s subscriptions` list subscribed channels", command),
+	}, "\n")
+}
+
+// Number of transitions shown by the history command
+const (
+	slackHistoryDefault = 10
+	slackHistoryMax     = 25 // keeps the reply under Slack's 3000 character section limit
+)
+
+// history replies with the most recent transitions
+func (h *slackHandler) history(args []string) slackMessage {
+	limit := slackHistoryDefault
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 || n > slackHistoryMax {
+			return slackReply(fmt.Sprintf("History length must be a number from 1 to %d", slackHistoryMax))
+		}
+		limit = n
+	}
+
+	entries, err := h.cache.History().Query(historyFilter{Limit: limit})
+	if err != nil {
+		return slackReply(fmt.Sprintf("Couldn't read the history: %v", err))
+	}
+	return renderSlackHistory(entries)
+}
+
+// subscribe registers a channel to receive transition notifications. Without a webhook URL
+// the configured default is used. Slack decides where a webhook posts, so the channel only
+// names the subscription; two channels sharing a webhook are refused since both would post
+// to the same place.
+func (h *slackHandler) subscribe(args []string, user string) slackMessage {
+	usage := "Usage: `subscribe #channel <webhook URL>`, using an incoming webhook created for the channel"
+	webhookURL := currentConfig().Slack.WebhookURL
+	if webhookURL != "" {
+		usage = "Usage: `subscribe #channel [webhook URL]`, using an incoming webhook created for the channel. Without a URL, the configured webhook is used"
+	}
+	if len(args) < 1 || len(args) > 2 || (len(args) == 1 && webhookURL == "") {
+		return slackReply(usage)
+	}
+	channel, err := parseSlackChannel(args[0])
+	if err != nil {
+		return slackReply(err.Error())
+	}
+	if len(args) == 2 {
+		webhookURL = strings.Trim(args[1], "<>") // Slack wraps links in angle brackets
+	}
+	if err := validateWebhookURL(webhookURL); err != nil {
+		return slackReply(err.Error())
+	}
+
+	subs, err := h.cache.Subscriptions().List()
+	if err != nil {
+		return slackReply(fmt.Sprintf("Couldn't read subscriptions: %v", err))
+	}
+	for _, sub := range subs {
+		if sub.WebhookURL == webhookURL && sub.Channel != channel {
+			return slackReply(fmt.Sprintf("That webhook already posts for %s. Incoming webhooks post to the channel they were created for, so create one for %s", sub.Channel, channel))
+		}
+	}
+
+	err = h.cache.Subscriptions().Add(subscription{
+		Channel:    channel,
+		WebhookURL: webhookURL,
+		CreatedBy:  user,
+		CreatedAt:  h.now(),
+	})
+	if err != nil {
+		return slackReply(fmt.Sprintf("Couldn't save the subscription: %v", err))
+	}
+	return slackReply(fmt.Sprintf("Status changes will be posted to %s", channel))
+}
+
+// unsubscribe stops notifications to a channel
+func (h *slackHandler) unsubscribe(args []string) slackMessage {
+	if len(args) != 1 {
+		return slackReply("Usage: `unsubscribe #channel`")
+	}
+	channel, err := parseSlackChannel(args[0])
+	if err != nil {
+		return slackReply(err.Error())
+	}
+
+	removed, err := h.cache.Subscriptions().Remove(channel)
+	if err != nil {
+		return slackReply(fmt.Sprintf("Couldn't remove the subscription: %v", err))
+	}
+	if !removed {
+		return slackReply(fmt.Sprintf("%s isn't subscribed", channel))
+	}
+	return slackReply(fmt.Sprintf("Status changes will no longer be posted to %s", channel))
+}
+
+// listSubscriptions replies with the subscribed channels, without their webhook URLs
+func (h *slackHandler) listSubscriptions() slackMessage {
+	subs, err := h.cache.Subscriptions().List()
+	if err != nil {
+		return slackReply(fmt.Sprintf("Couldn't read subscriptions: %v", err))
+	}
+	if len(subs) == 0 {
+		return slackReply("No channels are subscribed")
+	}
+
+	lines := make([]string, 0, len(subs))
+	for _, sub := range subs {
+		line := sub.Channel
+		if sub.CreatedBy != "" {
+			line += fmt.Sprintf(" (added by %s)", sub.CreatedBy)
+		}
+		lines = append(lines, line)
+	}
+	return slackReply("Status changes are posted to:\n" + strings.Join(lines, "\n"))
+}
+
+// parseSlackChannel returns a channel reference as #name. Slack sends channels as
+// <#C024BE7LR|name> when escaping is on, or as typed otherwise.
+func parseSlackChannel(arg string) (string, error) {
+	if strings.HasPrefix(arg, "<#") && strings.HasSuffix(arg, ">") {
+		id, name, _ := strings.Cut(arg[2:len(arg)-1], "|")
+		if name == "" {
+			name = id
+		}
+		return "#" + name, nil
+	}
+	if strings.HasPrefix(arg, "#") && len(arg) > 1 {
+		return arg, nil
+	}
+	return "", fmt.Errorf("expected a channel like #deploys, got %q", arg)
+}
+
+// filterSnapshot keeps only the selected regions of snapshot, in the selected order
+func filterSnapshot(snapshot statusSnapshot, selected []string) statusSnapshot {
+	byID := make(map[string]regionSnapshot, len(snapshot.Regions))
+	for _, entry := range snapshot.Regions {
+		byID[entry.ID] = entry
+	}
+	snapshot.Regions = make([]regionSnapshot, 0, len(selected))
+	for _, region := range selected {
+		if entry, ok := byID[region]; ok {
+			snapshot.Regions = append(snapshot.Regions, entry)
+		}
+	}
+	return snapshot
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSigningSecret = "8f742231b10e8888abcd99yyyzzz85a5"
+
+// signedSlackRequest builds a slash command request signed as Slack would at time at
+func signedSlackRequest(secret string, form url.Values, at time.Time) *http.Request {
+	body := form.Encode()
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+
+	req := httptest.NewRequest("POST", "/slack/command", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Slack-Request-Timestamp", timestamp)
+	req.Header.Set("X-Slack-Signature", slackSignature(secret, timestamp, []byte(body)))
+	return req
+}
+
+func newTestSlackHandler(t *testing.T) (*slackHandler, time.Time) {
+	t.Helper()
+	cache := NewStatusCacheWithPath(filepath.Join(t.TempDir(), "statuses.json"))
+	cache.UpdateAll(map[string]statusResult{
+		"overall": {region: "overall", status: "testing"},
+		"au":      {region: "au", status: "complete"},
+		"ca":      {region: "ca", err: errors.New("HTTP 503"), attempts: 3},
+		"or":      {region: "or", status: "pr"},
+		"us":      {region: "us", status: "testfail"},
+	})
+
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	h := newSlackHandler(cache, testSigningSecret)
+	h.now = func() time.Time { return now }
+	return h, now
+}
+
+func TestSlackSignature_KnownValue(t *testing.T) {
+	// Example from Slack's request verification documentation
+	body := "token=xyzz0WbapA4vBCDEFasx0q6G&team_id=T1DC2JH3J&team_domain=testteamnow&channel_id=G8PSS9T3V&channel_name=foobar&user_id=U2CERLKJA&user_name=roadrunner&command=%2Fwebhook-collect&text=&response_url=https%3A%2F%2Fhooks.slack.com%2Fcommands%2FT1DC2JH3J%2F397700885554%2F96rGlfmibIGlgcZRskXaIFfN&trigger_id=398738663015.47445629121.803a0bc887a14d10d2c447fce8b6703c"
+	expected := "v0=a2114d57b48eac39b9ad189dd8316235a7b4a8d21a10bd27519666489c69b503"
+
+	if got := slackSignature(testSigningSecret, "1531420618", []byte(body)); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestSlackHandler_RespondsWithBlocks(t *testing.T) {
+	h, now := newTestSlackHandler(t)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, signedSlackRequest(testSigningSecret, url.Values{"command": {"/deploy-status"}}, now))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var msg slackMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &msg); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if msg.ResponseType != "ephemeral" {
+		t.Errorf("expected ephemeral response, got %q", msg.ResponseType)
+	}
+	if len(msg.Blocks) != 2 || msg.Blocks[0].Type != "section" || msg.Blocks[1].Type != "context" {
+		t.Fatalf("expected a section and a context block, got %+v", msg.Blocks)
+	}
+
+	expected := strings.Join([]string{
+		"🟩 *Status:* testing",
+		"⬜ *AU:* complete",
+		"🟥 *CA:* HTTP 503 (after 3 attempts)",
+		"🟦 *OR:* pr",
+		"🟥 *US:* testfail",
+	}, "\n")
+	if msg.Blocks[0].Text.Text != expected {
+		t.Errorf("unexpected section text:\n%s", msg.Blocks[0].Text.Text)
+	}
+	if !strings.HasPrefix(msg.Blocks[1].Elements[0].Text, "Last changed <!date^") {
+		t.Errorf("unexpected context text %q", msg.Blocks[1].Elements[0].Text)
+	}
+}
+
+func TestSlackHandler_RejectsInvalidRequests(t *testing.T) {
+	h, now := newTestSlackHandler(t)
+	form := url.Values{"command": {"/deploy-status"}}
+
+	tests := map[string]*http.Request{
+		"wrong secret":     signedSlackRequest("other-secret", form, now),
+		"stale timestamp":  signedSlackRequest(testSigningSecret, form, now.Add(-10*time.Minute)),
+		"future timestamp": signedSlackRequest(testSigningSecret, form, now.Add(10*time.Minute)),
+		"missing headers":  httptest.NewRequest("POST", "/slack/command", strings.NewReader(form.Encode())),
+	}
+
+	tampered := signedSlackRequest(testSigningSecret, form, now)
+	tampered.Body = http.NoBody
+	tests["tampered body"] = tampered
+
+	for name, req := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected 401, got %d", name, rec.Code)
+		}
+	}
+}
+
+func TestRenderSlackStatus_NoStatuses(t *testing.T) {
+	msg := renderSlackStatus(statusSnapshot{})
+
+	if len(msg.Blocks) != 1 || !strings.Contains(msg.Blocks[0].Text.Text, "No statuses") {
+		t.Errorf("unexpected blocks: %+v", msg.Blocks)
+	}
+}
+
+func TestServer_SlackCommandRequiresSigningSecret(t *testing.T) {
+	withConfig(t, func(cfg *Config) { cfg.Slack = SlackConfig{} })
+	srv, _ := newTestServer(t)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, signedSlackRequest(testSigningSecret, url.Values{}, time.Now()))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without a signing secret, got %d", rec.Code)
+	}
+
+	withConfig(t, func(cfg *Config) { cfg.Slack = SlackConfig{SigningSecret: testSigningSecret} })
+	srv, _ = newTestServer(t)
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, signedSlackRequest(testSigningSecret, url.Values{}, time.Now()))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected 200 with a signing secret, got %d", rec.Code)
+	}
+}
+
+func respondTo(h *slackHandler, text string) slackMessage {
+	return h.respond(url.Values{"command": {"/deploy-status"}, "text": {text}, "user_name": {"alice"}})
+}
+
+func TestSlackHandler_RegionFilter(t *testing.T) {
+	h, _ := newTestSlackHandler(t)
+
+	msg := respondTo(h, "US au")
+	expected := "🟥 *US:* testfail\n⬜ *AU:* complete"
+	if msg.Blocks[0].Text.Text != expected {
+		t.Errorf("unexpected section text:\n%s", msg.Blocks[0].Text.Text)
+	}
+
+	msg = respondTo(h, "eu")
+	if !strings.Contains(msg.Text, `unknown region "eu"`) || !strings.Contains(msg.Text, "history [N]") {
+		t.Errorf("expected unknown region error with usage, got %q", msg.Text)
+	}
+}
+
+func TestSlackHandler_History(t *testing.T) {
+	h, _ := newTestSlackHandler(t)
+	h.cache.UpdateAll(map[string]statusResult{"overall": {region: "overall", status: "testok"}})
+	h.cache.UpdateAll(map[string]statusResult{"overall": {region: "overall", status: "merging"}})
+
+	msg := respondTo(h, "history 2")
+	lines := strings.Split(msg.Blocks[0].Text.Text, "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", msg.Blocks[0].Text.Text)
+	}
+	if !strings.HasSuffix(lines[0], "🟩 *Status:* testing → testok") || !strings.HasSuffix(lines[1], "🟩 *Status:* testok → merging") {
+		t.Errorf("unexpected history lines: %q", lines)
+	}
+
+	for _, arg := range []string{"0", "abc", "26"} {
+		if msg := respondTo(h, "history "+arg); !strings.Contains(msg.Text, "from 1 to 25") {
+			t.Errorf("history %s: expected a range error, got %q", arg, msg.Text)
+		}
+	}
+}
+
+func TestSlackHandler_SubscribeAndUnsubscribe(t *testing.T) {
+	h, now := newTestSlackHandler(t)
+
+	msg := respondTo(h, "subscribe <#C024BE7LR|deploys> <https://hooks.slack.com/services/T0/B0/X>")
+	if msg.Text != "Status changes will be posted to #deploys" {
+		t.Fatalf("unexpected reply %q", msg.Text)
+	}
+
+	subs, _ := h.cache.Subscriptions().List()
+	if len(subs) != 1 {
+		t.Fatalf("expected 1 subscription, got %+v", subs)
+	}
+	sub := subs[0]
+	if sub.Channel != "#deploys" || sub.WebhookURL != "https://hooks.slack.com/services/T0/B0/X" ||
+		sub.CreatedBy != "alice" || !sub.CreatedAt.Equal(now) {
+		t.Errorf("unexpected subscription %+v", sub)
+	}
+
+	msg = respondTo(h, "subscriptions")
+	if !strings.Contains(msg.Text, "#deploys (added by alice)") || strings.Contains(msg.Text, "hooks.slack.com") {
+		t.Errorf("expected channel listed without its webhook URL, got %q", msg.Text)
+	}
+
+	if msg := respondTo(h, "unsubscribe #deploys"); !strings.Contains(msg.Text, "no longer be posted") {
+		t.Errorf("unexpected reply %q", msg.Text)
+	}
+	if msg := respondTo(h, "unsubscribe #deploys"); msg.Text != "#deploys isn't subscribed" {
+		t.Errorf("unexpected reply %q", msg.Text)
+	}
+}
+
+func TestSlackHandler_SubscribeUsesConfiguredWebhook(t *testing.T) {
+	h, _ := newTestSlackHandler(t)
+
+	if msg := respondTo(h, "subscribe #deploys"); !strings.HasPrefix(msg.Text, "Usage:") {
+		t.Errorf("expected usage without a configured webhook, got %q", msg.Text)
+	}
+
+	const defaultURL = "https://hooks.slack.com/services/T0/B0/DEFAULT"
+	withConfig(t, func(cfg *Config) { cfg.Slack.WebhookURL = defaultURL })
+
+	if msg := respondTo(h, "subscribe #deploys"); msg.Text != "Status changes will be posted to #deploys" {
+		t.Fatalf("unexpected reply %q", msg.Text)
+	}
+	subs, _ := h.cache.Subscriptions().List()
+	if len(subs) != 1 || subs[0].WebhookURL != defaultURL {
+		t.Errorf("expected the configured webhook to be stored, got %+v", subs)
+	}
+
+	// The webhook can only post to one channel
+	if msg := respondTo(h, "subscribe #other"); !strings.Contains(msg.Text, "already posts for #deploys") {
+		t.Errorf("expected a second channel on the same webhook to be refused, got %q", msg.Text)
+	}
+	if subs, _ := h.cache.Subscriptions().List(); len(subs) != 1 {
+		t.Errorf("expected only the first subscription, got %+v", subs)
+	}
+}
+
+func TestSlackHandler_SubscribeRejectsInvalidArguments(t *testing.T) {
+	h, _ := newTestSlackHandler(t)
+
+	for _, text := range []string{
+		"subscribe #deploys",
+		"subscribe deploys https://hooks.slack.com/services/T0/B0/X",
+		"subscribe #deploys https://example.com/webhook",
+	} {
+		respondTo(h, text)
+	}
+
+	if subs, _ := h.cache.Subscriptions().List(); len(subs) != 0 {
+		t.Errorf("expected no subscriptions to be stored, got %+v", subs)
+	}
+}
+
+func TestParseSlackChannel(t *testing.T) {
+	tests := map[string]string{
+		"<#C024BE7LR|deploys>": "#deploys",
+		"<#C024BE7LR>":         "#C024BE7LR",
+		"#deploys":             "#deploys",
+	}
+	for arg, expected := range tests {
+		if channel, err := parseSlackChannel(arg); err != nil || channel != expected {
+			t.Errorf("parseSlackChannel(%q) = %q, %v; expected %q", arg, channel, err, expected)
+		}
+	}
+
+	for _, arg := range []string{"deploys", "#", "<@U123>"} {
+		if _, err := parseSlackChannel(arg); err == nil {
+			t.Errorf("parseSlackChannel(%q): expected error", arg)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// statsPhases are the deployment phases reported by the stats subcommand, in pipeline order
+var statsPhases = []string{"building", "testing", "merging", "deploy"}
+
+// deployCycle is one run of a region from leaving complete until reaching complete again
+type deployCycle struct {
+	Region  string
+	Start   time.Time
+	End     time.Time
+	Phases  map[string]time.Duration
+	Entries []transition // transitions from leaving complete through returning to it
+}
+
+// Total returns how long the cycle took from start to end
+func (c deployCycle) Total() time.Duration {
+	return c.End.Sub(c.Start)
+}
+
+// durationSummary describes the spread of a set of durations
+type durationSummary struct {
+	Count int
+	P50   time.Duration
+	P90   time.Duration
+	Max   time.Duration
+}
+
+// buildCycles reconstructs completed deployment cycles per region from transitions.
+// A cycle starts when a region leaves complete and ends when it returns to complete;
+// time spent in each status along the way is added to that status's phase duration.
+func buildCycles(entries []transition) map[string][]deployCycle {
+	type cycleTracker struct {
+		cycle     *deployCycle
+		status    string
+		enteredAt time.Time
+	}
+
+	states := make(map[string]*cycleTracker)
+	cycles := make(map[string][]deployCycle)
+
+	for _, entry := range entries {
+		state, ok := states[entry.Region]
+		if !ok {
+			state = &cycleTracker{}
+			states[entry.Region] = state
+		}
+
+		if state.cycle != nil {
+			state.cycle.Phases[state.status] += entry.ChangedAt.Sub(state.enteredAt)
+			state.cycle.Entries = append(state.cycle.Entries, entry)
+		}
+
+		to := strings.ToLower(entry.To)
+		switch {
+		case to == "complete" && state.cycle != nil:
+			state.cycle.End = entry.ChangedAt
+			cycles[entry.Region] = append(cycles[entry.Region], *state.cycle)
+			state.cycle = nil
+		case to != "complete" && state.cycle == nil && strings.EqualFold(entry.From, "complete"):
+			state.cycle = &deployCycle{
+				Region:  entry.Region,
+				Start:   entry.ChangedAt,
+				Phases:  make(map[string]time.Duration),
+				Entries: []transition{entry},
+			}
+		}
+
+		state.status = to
+		state.enteredAt = entry.ChangedAt
+	}
+
+	return cycles
+}
+
+// percentile returns the nearest-rank p-th percentile (0-100) of sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank-1, 0)]
+}
+
+// summarize returns the p50, p90 and max of durations
+func summarize(durations []time.Duration) durationSummary {
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+
+	summary := durationSummary{Count: len(sorted)}
+	if len(sorted) > 0 {
+		summary.P50 = percentile(sorted, 50)
+		summary.P90 = percentile(sorted, 90)
+		summary.Max = sorted[len(sorted)-1]
+	}
+	return summary
+}
+
+// summarizeCycles summarizes each phase, plus "total", across cycles.
+// Cycles that skipped a phase don't count towards that phase.
+func summarizeCycles(cycles []deployCycle) map[string]durationSummary {
+	summaries := make(map[string]durationSummary)
+
+	var totals []time.Duration
+	for _, cycle := range cycles {
+		totals = append(totals, cycle.Total())
+	}
+	summaries["total"] = summarize(totals)
+
+	for _, phase := range statsPhases {
+		var durations []time.Duration
+		for _, cycle := range cycles {
+			if d, ok := cycle.Phases[phase]; ok {
+				durations = append(durations, d)
+			}
+		}
+		summaries[phase] = summarize(durations)
+	}
+	return summaries
+}
+
+// formatDuration formats d rounded to the second, or "-" if zero
+func formatDuration(d time.Duration) string {
+	if d <= 0 {
+		return "-"
+	}
+	return d.Round(time.Second).String()
+}
+
+// printStats writes a per-cycle table and summary for each region to w
+func printStats(w io.Writer, selected []string, cycles map[string][]deployCycle) {
+	for i, region := range selected {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+
+		regionCycles := cycles[region]
+		if len(regionCycles) == 0 {
+			fmt.Fprintf(w, "%s: no completed deployments recorded\n", regionLabel(region))
+			continue
+		}
+		fmt.Fprintf(w, "%s (last %d deployments)\n", regionLabel(region), len(regionCycles))
+
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "Started\t%s\ttotal\n", strings.Join(statsPhases, "\t"))
+		for _, cycle := range regionCycles {
+			fmt.Fprint(tw, cycle.Start.Local().Format("Mon Jan 2 15:04"))
+			for _, phase := range statsPhases {
+				fmt.Fprintf(tw, "\t%s", formatDuration(cycle.Phases[phase]))
+			}
+			fmt.Fprintf(tw, "\t%s\n", formatDuration(cycle.Total()))
+		}
+
+		summaries := summarizeCycles(regionCycles)
+		rows := []struct {
+			name string
+			get  func(durationSummary) time.Duration
+		}{
+			{"p50", func(s durationSummary) time.Duration { return s.P50 }},
+			{"p90", func(s durationSummary) time.Duration { return s.P90 }},
+			{"max", func(s durationSummary) time.Duration { return s.Max }},
+		}
+		for _, row := range rows {
+			fmt.Fprint(tw, row.name)
+			for _, phase := range statsPhases {
+				fmt.Fprintf(tw, "\t%s", formatDuration(row.get(summaries[phase])))
+			}
+			fmt.Fprintf(tw, "\t%s\n", formatDuration(row.get(summaries["total"])))
+		}
+		tw.Flush()
+	}
+}
+
+// Stats JSON output, with durations in seconds
+type (
+	regionStatsJSON struct {
+		Region  string                 `json:"region"`
+		Cycles  []cycleJSON            `json:"cycles"`
+		Summary map[string]summaryJSON `json:"summary"`
+	}
+	cycleJSON struct {
+		Start  time.Time          `json:"start"`
+		End    time.Time          `json:"end"`
+		Total  float64            `json:"totalSeconds"`
+		Phases map[string]float64 `json:"phaseSeconds"`
+	}
+	summaryJSON struct {
+		Count int     `json:"count"`
+		P50   float64 `json:"p50Seconds"`
+		P90   float64 `json:"p90Seconds"`
+		Max   float64 `json:"maxSeconds"`
+	}
+)
+
+// statsJSON converts a region's cycles and their summary to the JSON output form
+func statsJSON(region string, cycles []deployCycle) regionStatsJSON {
+	out := regionStatsJSON{
+		Region:  region,
+		Cycles:  make([]cycleJSON, 0, len(cycles)),
+		Summary: make(map[string]summaryJSON),
+	}
+	for _, cycle := range cycles {
+		phases := make(map[string]float64, len(cycle.Phases))
+		for phase, d := range cycle.Phases {
+			phases[phase] = d.Seconds()
+		}
+		out.Cycles = append(out.Cycles, cycleJSON{
+			Start:  cycle.Start,
+			End:    cycle.End,
+			Total:  cycle.Total().Seconds(),
+			Phases: phases,
+		})
+	}
+	for name, summary := range summarizeCycles(cycles) {
+		out.Summary[name] = summaryJSON{
+			Count: summary.Count,
+			P50:   summary.P50.Seconds(),
+			P90:   summary.P90.Seconds(),
+			Max:   summary.Max.Seconds(),
+		}
+	}
+	return out
+}
+
+// runStats implements the stats subcommand
+func runStats(args []string) int {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path to config file (default: user config dir)")
+	regionsFlag := fs.String("regions", "", "Comma-separated regions to report (default: all)")
+	count := fs.Int("cycles", 10, "Number of most recent completed deployments to include")
+	output := fs.String("output", outputText, "Output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if *output != outputText && *output != outputJSON {
+		fmt.Fprintf(os.Stderr, "Error: unknown output format %q (expected text or json)\n", *output)
+		return exitUsage
+	}
+	if *count < 1 {
+		fmt.Fprintln(os.Stderr, "Error: --cycles must be at least 1")
+		return exitUsage
+	}
+
+	cache, ok := initialize(*configPath)
+	if !ok {
+		return exitError
+	}
+
+	selected, err := parseRegions(currentConfig(), *regionsFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+
+	entries, err := cache.History().Entries()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+
+	cycles := buildCycles(entries)
+	for region, regionCycles := range cycles {
+		if len(regionCycles) > *count {
+			cycles[region] = regionCycles[len(regionCycles)-*count:]
+		}
+	}
+
+	if *output == outputJSON {
+		report := make([]regionStatsJSON, 0, len(selected))
+		for _, region := range selected {
+			report = append(report, statsJSON(region, cycles[region]))
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+			return exitError
+		}
+		return exitOK
+	}
+
+	printStats(os.Stdout, selected, cycles)
+	return exitOK
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cycleTransitions builds transitions for region moving through statuses, each step
+// taking the matching number of minutes
+func cycleTransitions(region string, start time.Time, statuses []string, minutes []int) []transition {
+	var entries []transition
+	at := start
+	for i := 1; i < len(statuses); i++ {
+		entries = append(entries, transition{Region: region, From: statuses[i-1], To: statuses[i], ChangedAt: at})
+		at = at.Add(time.Duration(minutes[i-1]) * time.Minute)
+	}
+	return entries
+}
+
+func TestBuildCycles(t *testing.T) {
+	start := time.Date(2026, 1, 30, 12, 0, 0, 0, time.UTC)
+	statuses := []string{"complete", "building", "testing", "testok", "merging", "deploy", "complete"}
+	entries := cycleTransitions("overall", start, statuses, []int{10, 20, 1, 5, 15, 0})
+
+	cycles := buildCycles(entries)["overall"]
+	if len(cycles) != 1 {
+		t.Fatalf("expected 1 cycle, got %d", len(cycles))
+	}
+
+	cycle := cycles[0]
+	expected := map[string]time.Duration{
+		"building": 10 * time.Minute,
+		"testing":  20 * time.Minute,
+		"testok":   time.Minute,
+		"merging":  5 * time.Minute,
+		"deploy":   15 * time.Minute,
+	}
+	for phase, d := range expected {
+		if cycle.Phases[phase] != d {
+			t.Errorf("%s: expected %v, got %v", phase, d, cycle.Phases[phase])
+		}
+	}
+	if cycle.Total() != 51*time.Minute {
+		t.Errorf("expected total 51m, got %v", cycle.Total())
+	}
+}
+
+func TestBuildCycles_SumsRepeatedPhasesAndSkipsPartialCycles(t *testing.T) {
+	start := time.Date(2026, 1, 30, 12, 0, 0, 0, time.UTC)
+
+	// First seen mid-deployment, so no full cycle can be reconstructed
+	entries := cycleTransitions("au", start, []string{"", "testing", "complete"}, []int{10, 0})
+
+	// A cycle where a test failure sends the region back to testing
+	entries = append(entries, cycleTransitions("au", start.Add(time.Hour),
+		[]string{"complete", "testing", "testfail", "testing", "complete"}, []int{10, 5, 10, 0})...)
+
+	// An unfinished cycle
+	entries = append(entries, cycleTransitions("au", start.Add(2*time.Hour),
+		[]string{"complete", "building"}, []int{0})...)
+
+	cycles := buildCycles(entries)["au"]
+	if len(cycles) != 1 {
+		t.Fatalf("expected 1 completed cycle, got %d", len(cycles))
+	}
+	if cycles[0].Phases["testing"] != 20*time.Minute {
+		t.Errorf("expected repeated testing phases to be summed to 20m, got %v", cycles[0].Phases["testing"])
+	}
+	if cycles[0].Phases["testfail"] != 5*time.Minute {
+		t.Errorf("expected testfail to be 5m, got %v", cycles[0].Phases["testfail"])
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	var durations []time.Duration
+	for i := 10; i >= 1; i-- {
+		durations = append(durations, time.Duration(i)*time.Minute)
+	}
+
+	summary := summarize(durations)
+
+	if summary.Count != 10 {
+		t.Errorf("expected count 10, got %d", summary.Count)
+	}
+	if summary.P50 != 5*time.Minute {
+		t.Errorf("expected p50 5m, got %v", summary.P50)
+	}
+	if summary.P90 != 9*time.Minute {
+		t.Errorf("expected p90 9m, got %v", summary.P90)
+	}
+	if summary.Max != 10*time.Minute {
+		t.Errorf("expected max 10m, got %v", summary.Max)
+	}
+
+	if empty := summarize(nil); empty != (durationSummary{}) {
+		t.Errorf("expected zero summary for no durations, got %+v", empty)
+	}
+}
+
+func TestSummarizeCycles_SkipsMissingPhases(t *testing.T) {
+	cycles := []deployCycle{
+		{Phases: map[string]time.Duration{"building": time.Minute, "deploy": 4 * time.Minute}},
+		{Phases: map[string]time.Duration{"building": 3 * time.Minute}},
+	}
+
+	summaries := summarizeCycles(cycles)
+
+	if summaries["building"].Count != 2 || summaries["deploy"].Count != 1 {
+		t.Errorf("unexpected counts: building %d, deploy %d", summaries["building"].Count, summaries["deploy"].Count)
+	}
+	if summaries["deploy"].Max != 4*time.Minute {
+		t.Errorf("expected deploy max 4m, got %v", summaries["deploy"].Max)
+	}
+}
+
+func TestPrintStats(t *testing.T) {
+	start := time.Date(2026, 1, 30, 12, 0, 0, 0, time.UTC)
+	entries := cycleTransitions("overall", start,
+		[]string{"complete", "building", "testing", "merging", "deploy", "complete"}, []int{8, 20, 3, 12, 0})
+
+	var buf bytes.Buffer
+	printStats(&buf, []string{"overall", "au"}, buildCycles(entries))
+
+	output := buf.String()
+	for _, expected := range []string{"Status (last 1 deployments)", "p50", "p90", "max", "20m0s", "43m0s", "AU: no completed deployments recorded"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected output to contain %q, got:\n%s", expected, output)
+		}
+	}
+}
+
+func TestStatsJSON_UsesSeconds(t *testing.T) {
+	cycles := []deployCycle{{
+		Start:  time.Date(2026, 1, 30, 12, 0, 0, 0, time.UTC),
+		End:    time.Date(2026, 1, 30, 12, 30, 0, 0, time.UTC),
+		Phases: map[string]time.Duration{"testing": 90 * time.Second},
+	}}
+
+	out := statsJSON("overall", cycles)
+
+	if out.Cycles[0].Total != 1800 || out.Cycles[0].Phases["testing"] != 90 {
+		t.Errorf("unexpected cycle: %+v", out.Cycles[0])
+	}
+	if out.Summary["testing"].P50 != 90 {
+		t.Errorf("unexpected summary: %+v", out.Summary["testing"])
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// slackWebhookPrefix is the only destination subscriptions may post to, so the slash
+// command can't be used to make the server send requests elsewhere
+const slackWebhookPrefix = "https://hooks.slack.com/"
+
+// slackQueueSize is how many transitions and alerts are queued for posting before new ones
+// are dropped
+const slackQueueSize = 100
+
+// subscription is a Slack channel receiving transition notifications through an
+// incoming webhook
+type subscription struct {
+	Channel    string    `json:"channel"`
+	WebhookURL string    `json:"webhookUrl"`
+	CreatedBy  string    `json:"createdBy,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// SubscriptionStore persists subscriptions as a JSON file next to the status cache
+type SubscriptionStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewSubscriptionStore creates a SubscriptionStore reading and writing path
+func NewSubscriptionStore(path string) *SubscriptionStore {
+	return &SubscriptionStore{path: path}
+}
+
+// list reads the stored subscriptions. Callers must hold s.mu and the file lock.
+func (s *SubscriptionStore) list() ([]subscription, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read subscriptions: %w", err)
+	}
+
+	var subs []subscription
+	if err := json.Unmarshal(data, &subs); err != nil {
+		return nil, fmt.Errorf("failed to parse subscriptions: %w", err)
+	}
+	return subs, nil
+}
+
+// write replaces the stored subscriptions. Callers must hold s.mu and the file lock.
+func (s *SubscriptionStore) write(subs []subscription) error {
+	data, err := json.MarshalIndent(subs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal subscriptions: %w", err)
+	}
+	// Webhook URLs are credentials, so the file is only readable by its owner
+	if err := writeFileAtomic(s.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write subscriptions: %w", err)
+	}
+	return nil
+}
+
+// update applies fn to the stored subscriptions under the store's locks and saves the result
+func (s *SubscriptionStore) update(fn func([]subscription) []subscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return withFileLock(s.path+".lock", func() error {
+		subs, err := s.list()
+		if err != nil {
+			return err
+		}
+		return s.write(fn(subs))
+	})
+}
+
+// List returns every subscription
+func (s *SubscriptionStore) List() ([]subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var subs []subscription
+	err := withFileLock(s.path+".lock", func() error {
+		var err error
+		subs, err = s.list()
+		return err
+	})
+	return subs, err
+}
+
+// Add stores sub, replacing any existing subscription for the same channel
+func (s *SubscriptionStore) Add(sub subscription) error {
+	return s.update(func(subs []subscription) []subscription {
+		kept := make([]subscription, 0, len(subs)+1)
+		for _, existing := range subs {
+			if existing.Channel != sub.Channel {
+				kept = append(kept, existing)
+			}
+		}
+		return append(kept, sub)
+	})
+}
+
+// Remove deletes the subscription for channel, reporting whether one existed
+func (s *SubscriptionStore) Remove(channel string) (bool, error) {
+	removed := false
+	err := s.update(func(subs []subscription) []subscription {
+		kept := make([]subscription, 0, len(subs))
+		for _, existing := range subs {
+			if existing.Channel == channel {
+				removed = true
+				continue
+			}
+			kept = append(kept, existing)
+		}
+		return kept
+	})
+	return removed, err
+}
+
+// validateWebhookURL checks that url is a Slack incoming webhook
+func validateWebhookURL(url string) error {
+	if !strings.HasPrefix(url, slackWebhookPrefix) || len(url) == len(slackWebhookPrefix) {
+		return fmt.Errorf("webhook URL must start with %s", slackWebhookPrefix)
+	}
+	return nil
+}
+
+// postSlackWebhook sends msg to a Slack incoming webhook, giving up when ctx is done
+func postSlackWebhook(ctx context.Context, url string, msg slackMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxBodyBytes))
+		return &HTTPStatusError{StatusCode: resp.StatusCode, Snippet: snippet(string(body))}
+	}
+	return nil
+}
+
+// slackPost is a message queued for every subscribed channel
+type slackPost struct {
+	what string // what's being posted, for errors
+	msg  slackMessage
+}
+
+// subscriptionNotifier posts transitions and alerts to every subscribed channel
+type subscriptionNotifier struct {
+	store *SubscriptionStore
+	post  func(ctx context.Context, url string, msg slackMessage) error
+	queue chan slackPost
+}
+
+// newSubscriptionNotifier creates a notifier for the subscriptions stored alongside cache
+func newSubscriptionNotifier(cache *StatusCache) *subscriptionNotifier {
+	return &subscriptionNotifier{
+		store: cache.Subscriptions(),
+		post:  postSlackWebhook,
+		queue: make(chan slackPost, slackQueueSize),
+	}
+}
+
+// enqueue queues transitions for posting without blocking, skipping first observations
+func (n *subscriptionNotifier) enqueue(entries []transition) {
+	for _, entry := range entries {
+		if entry.From != "" {
+			n.send(slackPost{
+				what: fmt.Sprintf("transition %s %s → %s", entry.Region, entry.From, entry.To),
+				msg:  renderSlackTransition(entry),
+			})
+		}
+	}
+}
+
+// enqueueAlert queues an alert firing or resolving for posting without blocking
+func (n *subscriptionNotifier) enqueueAlert(ev alertEvent) {
+	n.send(slackPost{what: "alert " + ev.Text(), msg: renderSlackAlert(ev)})
+}
+
+// send queues p for run to post, reporting it on stderr if the queue is full
+func (n *subscriptionNotifier) send(p slackPost) {
+	select {
+	case n.queue <- p:
+	default:
+		fmt.Fprintf(os.Stderr, "Error: Slack queue full, %s dropped\n", p.what)
+	}
+}
+
+// notify posts p to every subscription while ctx lasts, reporting the channels it's not
+// posted to on stderr
+func (n *subscriptionNotifier) notify(ctx context.Context, p slackPost) {
+	subs, err := n.store.List()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return
+	}
+
+	for _, sub := range subs {
+		if ctx.Err() != nil {
+			fmt.Fprintf(os.Stderr, "Error: shutting down, Slack %s to %s dropped\n", p.what, sub.Channel)
+			continue
+		}
+		if err := n.post(ctx, sub.WebhookURL, p.msg); err != nil {
+			fmt.Fprintf(os.Stderr, "Error notifying %s: %v\n", sub.Channel, err)
+		}
+	}
+}
+
+// run posts queued messages until ctx is done, then posts the messages still queued for up
+// to shutdownGrace, reporting any left after that on stderr
+func (n *subscriptionNotifier) run(ctx context.Context) {
+	drainCtx, cancel := graceContext(ctx, shutdownGrace)
+	defer cancel()
+
+	for {
+		select {
+		case p := <-n.queue:
+			n.notify(drainCtx, p)
+		case <-ctx.Done():
+			for {
+				select {
+				case p := <-n.queue:
+					if drainCtx.Err() != nil {
+						fmt.Fprintf(os.Stderr, "Error: shutting down, Slack %s dropped\n", p.what)
+						continue
+					}
+					n.notify(drainCtx, p)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// startSubscriptions posts transitions to the subscribed Slack channels in the background,
+// returning the notifier for alerts and a function that waits for the posts queued when ctx
+// is done.
+func startSubscriptions(ctx context.Context, cache *StatusCache, lease *Lease) (*subscriptionNotifier, func()) {
+	n := newSubscriptionNotifier(cache)
+	forwarded := followTransitions(ctx, cache, lease.Held, cache.Silences().filterTransitions(n.enqueue))
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		n.run(forwarded)
+	}()
+	return n, func() { <-stopped }
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSubscriptionStore_AddReplacesChannel(t *testing.T) {
+	store := NewSubscriptionStore(filepath.Join(t.TempDir(), "subscriptions.json"))
+
+	store.Add(subscription{Channel: "#deploys", WebhookURL: "https://hooks.slack.com/services/A"})
+	store.Add(subscription{Channel: "#ops", WebhookURL: "https://hooks.slack.com/services/B"})
+	store.Add(subscription{Channel: "#deploys", WebhookURL: "https://hooks.slack.com/services/C"})
+
+	subs, err := store.List()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(subs) != 2 {
+		t.Fatalf("expected 2 subscriptions, got %+v", subs)
+	}
+	if subs[0].Channel != "#ops" || subs[1].WebhookURL != "https://hooks.slack.com/services/C" {
+		t.Errorf("expected #deploys to be replaced, got %+v", subs)
+	}
+}
+
+func TestSubscriptionStore_PersistsAcrossInstances(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "subscriptions.json")
+	NewSubscriptionStore(path).Add(subscription{Channel: "#deploys", WebhookURL: "https://hooks.slack.com/services/A"})
+
+	subs, err := NewSubscriptionStore(path).List()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(subs) != 1 || subs[0].Channel != "#deploys" {
+		t.Errorf("expected subscription to be read back, got %+v", subs)
+	}
+
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("failed to stat: %v", err)
+		}
+		if perm := info.Mode().Perm(); perm != 0600 {
+			t.Errorf("expected subscriptions file to be private, got %v", perm)
+		}
+	}
+}
+
+func TestSubscriptionStore_Remove(t *testing.T) {
+	store := NewSubscriptionStore(filepath.Join(t.TempDir(), "subscriptions.json"))
+	store.Add(subscription{Channel: "#deploys", WebhookURL: "https://hooks.slack.com/services/A"})
+
+	if removed, err := store.Remove("#ops"); err != nil || removed {
+		t.Errorf("expected nothing removed for unknown channel, got %v, %v", removed, err)
+	}
+	if removed, err := store.Remove("#deploys"); err != nil || !removed {
+		t.Errorf("expected #deploys removed, got %v, %v", removed, err)
+	}
+	if subs, _ := store.List(); len(subs) != 0 {
+		t.Errorf("expected no subscriptions, got %+v", subs)
+	}
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	valid := []string{"https://hooks.slack.com/services/T000/B000/XXXX"}
+	invalid := []string{
+		"http://hooks.slack.com/services/T000/B000/XXXX",
+		"https://hooks.slack.com/",
+		"https://example.com/hooks.slack.com/",
+		"https://hooks.slack.com.example.com/services/X",
+	}
+
+	for _, url := range valid {
+		if err := validateWebhookURL(url); err != nil {
+			t.Errorf("expected %q to be valid, got %v", url, err)
+		}
+	}
+	for _, url := range invalid {
+		if err := validateWebhookURL(url); err == nil {
+			t.Errorf("expected %q to be rejected", url)
+		}
+	}
+}
+
+// recordingPoster records the messages posted by a subscriptionNotifier
+type recordingPoster struct {
+	mu    sync.Mutex
+	posts map[string][]slackMessage
+}
+
+func (p *recordingPoster) post(_ context.Context, url string, msg slackMessage) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.posts == nil {
+		p.posts = make(map[string][]slackMessage)
+	}
+	p.posts[url] = append(p.posts[url], msg)
+	return nil
+}
+
+func (p *recordingPoster) count(url string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.posts[url])
+}
+
+func newTestNotifier(t *testing.T) (*subscriptionNotifier, *recordingPoster, *StatusCache) {
+	t.Helper()
+	cache := NewStatusCacheWithPath(filepath.Join(t.TempDir(), "statuses.json"))
+	cache.Subscriptions().Add(subscription{Channel: "#deploys", WebhookURL: "https://hooks.slack.com/services/A"})
+	cache.Subscriptions().Add(subscription{Channel: "#ops", WebhookURL: "https://hooks.slack.com/services/B"})
+
+	poster := &recordingPoster{}
+	n := newSubscriptionNotifier(cache)
+	n.post = poster.post
+	return n, poster, cache
+}
+
+func TestSubscriptionNotifier_PostsToEverySubscription(t *testing.T) {
+	n, poster, _ := newTestNotifier(t)
+
+	n.enqueue([]transition{{ID: 1, Region: "au", From: "testing", To: "testfail", ChangedAt: time.Now()}})
+	n.notify(context.Background(), <-n.queue)
+
+	for _, url := range []string{"https://hooks.slack.com/services/A", "https://hooks.slack.com/services/B"} {
+		if poster.count(url) != 1 {
+			t.Fatalf("expected 1 post to %s, got %d", url, poster.count(url))
+		}
+	}
+	msg := poster.posts["https://hooks.slack.com/services/A"][0]
+	if msg.Text != "🟥 *AU:* testing → testfail" {
+		t.Errorf("unexpected notification text %q", msg.Text)
+	}
+	if msg.ResponseType != "" {
+		t.Errorf("expected no response_type for a webhook message, got %q", msg.ResponseType)
+	}
+}
+
+func TestSubscriptionNotifier_SkipsFirstObservations(t *testing.T) {
+	n, _, _ := newTestNotifier(t)
+
+	n.enqueue([]transition{{ID: 1, Region: "au", To: "complete", ChangedAt: time.Now()}})
+
+	if len(n.queue) != 0 {
+		t.Error("expected first observation not to be queued")
+	}
+}
+
+func TestSubscriptionNotifier_RunDrainsQueueOnShutdown(t *testing.T) {
+	n, poster, _ := newTestNotifier(t)
+
+	n.enqueue([]transition{{ID: 1, Region: "au", From: "testing", To: "testok", ChangedAt: time.Now()}})
+	n.enqueueAlert(alertEvent{Rule: "tests-failed", Region: "au", State: alertResolved})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	n.run(ctx)
+
+	if got := poster.count("https://hooks.slack.com/services/A"); got != 2 {
+		t.Errorf("expected the queued transition and alert to be posted, got %d posts", got)
+	}
+}
+
+func TestStartSubscriptions_PostsOnceWhileHoldingLease(t *testing.T) {
+	var mu sync.Mutex
+	var posted []string
+	originalClient := httpClient
+	defer func() { httpClient = originalClient }()
+	httpClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		var msg slackMessage
+		json.NewDecoder(req.Body).Decode(&msg)
+		mu.Lock()
+		posted = append(posted, msg.Text)
+		mu.Unlock()
+		return (&mockTransport{responses: map[string]mockResponse{req.URL.String(): {body: "ok"}}}).RoundTrip(req)
+	})}
+
+	dir := t.TempDir()
+	cache := NewStatusCacheWithPath(filepath.Join(dir, "statuses.json"))
+	cache.Subscriptions().Add(subscription{Channel: "#deploys", WebhookURL: "https://hooks.slack.com/services/A"})
+	cache.Silences().Add(silence{Region: "us", ExpiresAt: time.Now().Add(time.Hour)})
+	cache.UpdateAll(map[string]statusResult{
+		"au": {region: "au", status: "testing"},
+		"us": {region: "us", status: "testing"},
+	})
+	held := NewLease(filepath.Join(dir, "fetcher.lease"), leaseTTL)
+	held.TryAcquire()
+	notHeld := NewLease(held.path, leaseTTL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, waitHeld := startSubscriptions(ctx, cache, held)
+	_, waitNotHeld := startSubscriptions(ctx, NewStatusCacheWithPath(cache.filePath), notHeld)
+
+	// A one-shot check records the changes, so neither serve process fetched them
+	other := NewStatusCacheWithPath(cache.filePath)
+	other.UpdateAll(map[string]statusResult{
+		"au": {region: "au", status: "testok"},
+		"us": {region: "us", status: "testok"},
+	})
+
+	for deadline := time.Now().Add(eventsPollInterval + 3*time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		mu.Lock()
+		n := len(posted)
+		mu.Unlock()
+		if n > 0 {
+			break
+		}
+	}
+	// Give the process without the lease a chance to post it too
+	time.Sleep(eventsPollInterval)
+	cancel()
+	waitHeld()
+	waitNotHeld()
+
+	if len(posted) != 1 || posted[0] != "🟩 *AU:* testing → testok" {
+		t.Errorf("expected only the unsilenced change to be posted once, by the lease holder, got %q", posted)
+	}
+}
+
+func TestPostSlackWebhook(t *testing.T) {
+	originalClient := httpClient
+	defer func() { httpClient = originalClient }()
+
+	var received slackMessage
+	httpClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		json.NewDecoder(req.Body).Decode(&received)
+		return (&mockTransport{responses: map[string]mockResponse{req.URL.String(): {body: "ok"}}}).RoundTrip(req)
+	})}
+
+	msg := renderSlackTransition(transition{Region: "au", From: "testing", To: "testok", ChangedAt: time.Now()})
+	if err := postSlackWebhook(context.Background(), "https://hooks.slack.com/services/A", msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if received.Text != msg.Text || len(received.Blocks) != 2 {
+		t.Errorf("unexpected message received: %+v", received)
+	}
+
+	httpClient = &http.Client{Transport: &mockTransport{responses: map[string]mockResponse{
+		"https://hooks.slack.com/services/A": {statusCode: 404, body: "no_service"},
+	}}}
+	err := postSlackWebhook(context.Background(), "https://hooks.slack.com/services/A", msg)
+	if httpErr, ok := err.(*HTTPStatusError); !ok || httpErr.StatusCode != 404 {
+		t.Errorf("expected HTTP 404 error, got %v", err)
+	}
+}
+
+func TestPostSlackWebhook_AbortsWhenCancelled(t *testing.T) {
+	originalClient := httpClient
+	defer func() { httpClient = originalClient }()
+	httpClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		<-req.Context().Done() // Slack never answers
+		return nil, req.Context().Err()
+	})}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	done := make(chan error)
+	go func() {
+		done <- postSlackWebhook(ctx, "https://hooks.slack.com/services/A", slackMessage{Text: "hi"})
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected the post to be cut short, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the post to be aborted once its context was done")
+	}
+}
+
+// roundTripFunc adapts a function to http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
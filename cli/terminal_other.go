@@ -0,0 +1,11 @@
+//go:build !windows && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package main
+
+import "golang.org/x/sys/unix"
+
+// Requests reading and writing terminal settings
+const (
+	ioctlReadTermios  = unix.TCGETS
+	ioctlWriteTermios = unix.TCSETS
+)
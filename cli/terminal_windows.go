@@ -0,0 +1,87 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// resizePollInterval is how often the console size is checked, as Windows has no resize signal
+const resizePollInterval = 500 * time.Millisecond
+
+// isTerminal reports whether f is a console
+func isTerminal(f *os.File) bool {
+	var mode uint32
+	return windows.GetConsoleMode(windows.Handle(f.Fd()), &mode) == nil
+}
+
+// enableRawMode switches the console input f to raw mode, so keys are read one at a time
+// without being echoed and arrive as escape sequences, and enables escape sequence output.
+// It returns a function restoring the previous modes.
+func enableRawMode(f *os.File) (func() error, error) {
+	in := windows.Handle(f.Fd())
+	out := windows.Handle(os.Stdout.Fd())
+
+	var inMode, outMode uint32
+	if err := windows.GetConsoleMode(in, &inMode); err != nil {
+		return nil, err
+	}
+	if err := windows.GetConsoleMode(out, &outMode); err != nil {
+		return nil, err
+	}
+
+	raw := inMode&^(windows.ENABLE_ECHO_INPUT|windows.ENABLE_PROCESSED_INPUT|windows.ENABLE_LINE_INPUT) | windows.ENABLE_VIRTUAL_TERMINAL_INPUT
+	if err := windows.SetConsoleMode(in, raw); err != nil {
+		return nil, err
+	}
+	if err := windows.SetConsoleMode(out, outMode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING); err != nil {
+		windows.SetConsoleMode(in, inMode)
+		return nil, err
+	}
+
+	return func() error {
+		windows.SetConsoleMode(out, outMode)
+		return windows.SetConsoleMode(in, inMode)
+	}, nil
+}
+
+// terminalSize returns the width and height of the console window f
+func terminalSize(f *os.File) (int, int, error) {
+	var info windows.ConsoleScreenBufferInfo
+	if err := windows.GetConsoleScreenBufferInfo(windows.Handle(f.Fd()), &info); err != nil {
+		return 0, 0, err
+	}
+	return int(info.Window.Right-info.Window.Left) + 1, int(info.Window.Bottom-info.Window.Top) + 1, nil
+}
+
+// notifyResize sends on the returned channel whenever the console f is resized, until
+// stop is called
+func notifyResize(f *os.File) (<-chan struct{}, func()) {
+	resized := make(chan struct{}, 1)
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(resizePollInterval)
+		defer ticker.Stop()
+		width, height, _ := terminalSize(f)
+		for {
+			select {
+			case <-ticker.C:
+				w, h, err := terminalSize(f)
+				if err != nil || (w == width && h == height) {
+					continue
+				}
+				width, height = w, h
+				select {
+				case resized <- struct{}{}:
+				default:
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return resized, func() { close(done) }
+}
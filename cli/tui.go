@@ -0,0 +1,570 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// Terminal control sequences used by the full-screen display
+const (
+	enterFullScreen = "\x1b[?1049h\x1b[?25l\x1b[?7l" // alternate screen, hide cursor, no line wrap
+	exitFullScreen  = "\x1b[?7h\x1b[?25h\x1b[?1049l"
+)
+
+// TUI layout
+const (
+	tuiTimelineWindow = 12 * time.Hour // span of history shown in each region's timeline
+	tuiClockInterval  = time.Minute    // how often ages, timelines, ETAs and acks are redrawn
+	tuiMinTimeline    = 10             // narrowest timeline worth showing
+	tuiStatusWidth    = 16
+	tuiSinceWidth     = 7
+	tuiRowPrefix      = 2 + 2 + 11 + tuiStatusWidth + 1 + tuiSinceWidth // cursor, number, label, status, since
+)
+
+// Keys decoded from terminal input, besides printable characters
+const (
+	keyUp     = "up"
+	keyDown   = "down"
+	keyEnter  = "enter"
+	keyEscape = "esc"
+	keyCtrlC  = "ctrl-c"
+)
+
+// parseKeys decodes a read from the terminal into key names. Printable characters are
+// returned as themselves and unrecognized sequences are dropped.
+func parseKeys(input []byte) []string {
+	var keys []string
+	for i := 0; i < len(input); i++ {
+		switch b := input[i]; {
+		case b == 0x1b && i+1 < len(input) && (input[i+1] == '[' || input[i+1] == 'O'):
+			// Skip to the sequence's final byte, e.g. the A of ESC [ A or ESC [ 1 ; 5 A
+			j := i + 2
+			for j < len(input) && (input[j] < 0x40 || input[j] > 0x7e) {
+				j++
+			}
+			if j < len(input) {
+				switch input[j] {
+				case 'A':
+					keys = append(keys, keyUp)
+				case 'B':
+					keys = append(keys, keyDown)
+				}
+			}
+			i = j
+		case b == 0x1b:
+			keys = append(keys, keyEscape)
+		case b == '\r' || b == '\n':
+			keys = append(keys, keyEnter)
+		case b == 0x03:
+			keys = append(keys, keyCtrlC)
+		case b >= 0x20 && b < 0x7f:
+			keys = append(keys, string(rune(b)))
+		}
+	}
+	return keys
+}
+
+// tuiView is the screen the TUI is showing
+type tuiView int
+
+const (
+	tuiTableView   tuiView = iota // every visible region with its timeline
+	tuiHistoryView                // the selected region's transitions
+)
+
+// tuiAction is what the run loop does after a key press
+type tuiAction int
+
+const (
+	tuiNone tuiAction = iota
+	tuiRefresh
+	tuiQuit
+)
+
+// tuiData is what the TUI shows, read from the cache
+type tuiData struct {
+	cfg       *Config // the config when the cache was read, for the regions and alert rules
+	statuses  map[string]statusResult
+	history   []transition
+	acks      map[string]silence
+	alerts    []alertState
+	silenced  map[string]bool // firing alerts that are silenced, by alertKey
+	lastRead  time.Time       // when this process last read the cache
+	lastWrite time.Time       // when any process last wrote the cache
+	now       time.Time
+}
+
+// loadTUIData re-reads the cache and everything stored alongside it
+func loadTUIData(cache *StatusCache) tuiData {
+	cache.Reload()
+	d := tuiData{
+		cfg:       currentConfig(),
+		statuses:  cache.GetAll(),
+		acks:      acknowledgedRegions(cache),
+		silenced:  make(map[string]bool),
+		lastRead:  cache.GetLastReadAt(),
+		lastWrite: cache.GetFileModTime(), // Set in every pane, not just the fetcher
+		now:       time.Now(),
+	}
+	d.history, _ = cache.History().Entries() // Without history there are just no timelines
+	if len(d.cfg.Alerts) > 0 {
+		d.alerts, _ = firingAlerts(alertsPath(cache))
+		for _, alert := range d.alerts {
+			if cache.Silences().Silenced(alert.Region, alert.Rule) {
+				d.silenced[alertKey(alert.Rule, alert.Region)] = true
+			}
+		}
+	}
+	return d
+}
+
+// at returns d as of now without re-reading the cache, dropping acks that have expired
+func (d tuiData) at(now time.Time) tuiData {
+	acks := make(map[string]silence, len(d.acks))
+	for region, ack := range d.acks {
+		if now.Before(ack.ExpiresAt) {
+			acks[region] = ack
+		}
+	}
+	d.acks = acks
+	d.now = now
+	return d
+}
+
+// tui is the state of the full-screen watch display
+type tui struct {
+	regions  []string        // every configured region, in display order
+	hidden   map[string]bool // regions toggled off
+	selected int             // index into the visible regions
+	view     tuiView
+	width    int
+	height   int
+	message  string // shown in the footer until the next fetch, e.g. "Refreshing..."
+}
+
+// newTUI creates a display for regions
+func newTUI(regions []string) *tui {
+	return &tui{regions: regions, hidden: make(map[string]bool), width: 80, height: 24}
+}
+
+// setRegions changes the regions shown, keeping the hidden ones hidden
+func (t *tui) setRegions(regions []string) {
+	t.regions = regions
+	t.selected = max(min(t.selected, len(t.visible())-1), 0)
+}
+
+// visible returns the regions that aren't hidden
+func (t *tui) visible() []string {
+	var visible []string
+	for _, region := range t.regions {
+		if !t.hidden[region] {
+			visible = append(visible, region)
+		}
+	}
+	return visible
+}
+
+// selectedRegion returns the region under the cursor, if any region is visible
+func (t *tui) selectedRegion() (string, bool) {
+	visible := t.visible()
+	if len(visible) == 0 {
+		return "", false
+	}
+	return visible[min(t.selected, len(visible)-1)], true
+}
+
+// handleKey updates the display for a key press
+func (t *tui) handleKey(key string) tuiAction {
+	switch key {
+	case "q", keyCtrlC:
+		return tuiQuit
+	case "r":
+		t.message = "Refreshing..."
+		return tuiRefresh
+	}
+
+	if t.view == tuiHistoryView {
+		if key == keyEscape || key == "h" || key == keyEnter {
+			t.view = tuiTableView
+		}
+		return tuiNone
+	}
+
+	visible := t.visible()
+	switch key {
+	case keyUp, "k":
+		t.selected = max(t.selected-1, 0)
+	case keyDown, "j":
+		t.selected = max(min(t.selected+1, len(visible)-1), 0)
+	case "h", keyEnter:
+		if len(visible) > 0 {
+			t.view = tuiHistoryView
+		}
+	case "a":
+		clear(t.hidden)
+	default:
+		if n, err := strconv.Atoi(key); err == nil && n >= 1 && n <= len(t.regions) {
+			region := t.regions[n-1]
+			t.hidden[region] = !t.hidden[region]
+			t.selected = max(min(t.selected, len(t.visible())-1), 0)
+		}
+	}
+	return tuiNone
+}
+
+// fit truncates s to width characters
+func fit(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	if width == 1 {
+		return "…"
+	}
+	return string(runes[:width-1]) + "…"
+}
+
+// pad truncates or pads s to exactly width characters
+func pad(s string, width int) string {
+	s = fit(s, width)
+	return s + strings.Repeat(" ", max(width-len([]rune(s)), 0))
+}
+
+// formatAge formats how long ago something happened in a few characters, e.g. "12m"
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "<1m"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
+
+// timelineStatuses returns region's status at the end of each of cells equal slots making
+// up window up to now, or "" for slots before its first recorded transition
+func timelineStatuses(entries []transition, region string, now time.Time, window time.Duration, cells int) []string {
+	statuses := make([]string, cells)
+	slot := window / time.Duration(cells)
+	start := now.Add(-window)
+
+	current := ""
+	for i, next := 0, 0; i < cells; i++ {
+		end := start.Add(slot * time.Duration(i+1))
+		for ; next < len(entries) && !entries[next].ChangedAt.After(end); next++ {
+			if entries[next].Region == region {
+				current = entries[next].To
+			}
+		}
+		statuses[i] = current
+	}
+	return statuses
+}
+
+// timelineBars renders statuses as a sparkline: failures are full height, deployment steps
+// three quarters, pull requests half and complete a baseline, each in its status color
+func timelineBars(statuses []string) string {
+	var b strings.Builder
+	for i := 0; i < len(statuses); {
+		// Color each run of the same status at once
+		run := 1
+		for i+run < len(statuses) && statuses[i+run] == statuses[i] {
+			run++
+		}
+
+		status := statuses[i]
+		bar, barColor := "▁", getStatusColor(status)
+		if status == "" {
+			bar, barColor = "·", color.New(color.FgHiBlack)
+		} else {
+			switch classifyStatus(status) {
+			case classRed:
+				bar = "█"
+			case classGreen:
+				bar = "▆"
+			case classBlue:
+				bar = "▄"
+			}
+		}
+		b.WriteString(barColor.Sprint(strings.Repeat(bar, run)))
+		i += run
+	}
+	return b.String()
+}
+
+// lastChange returns when region's status last changed, if the history records it
+func lastChange(entries []transition, region string) (time.Time, bool) {
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Region == region {
+			return entries[i].ChangedAt, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// render returns the screen's lines for d, exactly t.height of them
+func (t *tui) render(d tuiData) []string {
+	var body []string
+	if t.view == tuiHistoryView {
+		body = t.renderHistory(d)
+	} else {
+		body = t.renderTable(d)
+	}
+
+	gray := color.New(color.FgHiBlack)
+	help := "↑↓ select  1-9 toggle  a show all  h history  r refresh  q quit"
+	if t.view == tuiHistoryView {
+		help = "esc back  r refresh  q quit"
+	}
+	status := t.message
+	if status == "" {
+		var parts []string
+		if !d.lastRead.IsZero() {
+			parts = append(parts, "read "+d.lastRead.Local().Format("15:04:05"))
+		}
+		if !d.lastWrite.IsZero() {
+			parts = append(parts, "written "+d.lastWrite.Local().Format("15:04:05"))
+		}
+		status = strings.Join(parts, "  ")
+	}
+	footer := gray.Sprint(fit(help, t.width))
+	if room := t.width - len([]rune(help)) - 2; room >= len(status) && status != "" {
+		footer = gray.Sprint(help + strings.Repeat(" ", room-len(status)+2) + status)
+	}
+
+	lines := body[:min(len(body), max(t.height-1, 0))]
+	for len(lines) < t.height-1 {
+		lines = append(lines, "")
+	}
+	return append(lines, footer)
+}
+
+// renderTable returns the lines of the region table
+func (t *tui) renderTable(d tuiData) []string {
+	bold := color.New(color.Bold)
+	gray := color.New(color.FgHiBlack)
+	yellow := color.New(color.FgYellow)
+
+	timelineWidth := t.width - tuiRowPrefix - 1
+	if timelineWidth < tuiMinTimeline {
+		timelineWidth = 0
+	}
+
+	lines := []string{bold.Sprint(fit("CSuite Deploy Status", t.width)), ""}
+	header := "    " + pad("REGION", 11) + pad("STATUS", tuiStatusWidth+1) + pad("SINCE", tuiSinceWidth)
+	if timelineWidth > 0 {
+		header += " " + fit(fmt.Sprintf("LAST %d HOURS", int(tuiTimelineWindow.Hours())), timelineWidth)
+	}
+	lines = append(lines, gray.Sprint(header))
+
+	selected, _ := t.selectedRegion()
+	detailIndent := strings.Repeat(" ", 15)
+	for _, region := range t.visible() {
+		result := d.statuses[region]
+		value := result.status
+		statusColor := getStatusColor(result.status)
+		if result.err != nil {
+			value = result.err.Error()
+			statusColor = color.New(color.FgRed)
+		}
+		ack, acked := d.acks[region]
+		if acked {
+			statusColor = yellow
+		}
+
+		cursor := "  "
+		if region == selected {
+			cursor = "> "
+		}
+		number := " "
+		if n := slices.Index(t.regions, region) + 1; n <= 9 {
+			number = strconv.Itoa(n) // Only the first nine regions can be toggled
+		}
+		since := ""
+		if changed, ok := lastChange(d.history, region); ok {
+			since = formatAge(d.now.Sub(changed))
+		}
+
+		row := bold.Sprint(cursor) + number + " " + pad(d.cfg.Label(region), 11) +
+			statusColor.Sprint(pad(value, tuiStatusWidth)) + " " + gray.Sprint(pad(since, tuiSinceWidth))
+		if timelineWidth > 0 {
+			row += " " + timelineBars(timelineStatuses(d.history, region, d.now, tuiTimelineWindow, timelineWidth))
+		}
+		lines = append(lines, row)
+
+		switch {
+		case acked:
+			lines = append(lines, detailIndent+gray.Sprint(fit(formatAck(ack, d.now), t.width-len(detailIndent))))
+		case result.err == nil && regionState(result) == stateInProgress:
+			if eta, ok := estimateCompletion(d.history, region, result.status, d.now); ok {
+				lines = append(lines, detailIndent+gray.Sprint(fit(formatETA(eta), t.width-len(detailIndent))))
+			}
+		}
+	}
+
+	var hidden []string
+	for i, region := range t.regions {
+		if t.hidden[region] {
+			hidden = append(hidden, fmt.Sprintf("%d %s", i+1, d.cfg.Label(region)))
+		}
+	}
+	if len(hidden) > 0 {
+		lines = append(lines, "", gray.Sprint(fit("hidden: "+strings.Join(hidden, ", "), t.width)))
+	}
+
+	if len(d.alerts) > 0 {
+		red := color.New(color.FgRed, color.Bold)
+		lines = append(lines, "")
+		for _, alert := range d.alerts {
+			if d.silenced[alertKey(alert.Rule, alert.Region)] {
+				lines = append(lines, gray.Sprint(fit(describeAlert(d.cfg, alert)+" (silenced)", t.width)))
+				continue
+			}
+			lines = append(lines, red.Sprint(fit(describeAlert(d.cfg, alert), t.width)))
+		}
+	}
+	return lines
+}
+
+// renderHistory returns the lines of the selected region's history, newest first
+func (t *tui) renderHistory(d tuiData) []string {
+	bold := color.New(color.Bold)
+	gray := color.New(color.FgHiBlack)
+
+	region, _ := t.selectedRegion()
+	lines := []string{bold.Sprint(fit(d.cfg.Label(region)+" history", t.width)), ""}
+
+	var entries []transition
+	for _, entry := range d.history {
+		if entry.Region == region {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) == 0 {
+		return append(lines, gray.Sprint("No status transitions recorded"))
+	}
+
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		from := entry.From
+		if from == "" {
+			from = "(first seen)"
+		}
+		lasted := "current, " + formatDuration(d.now.Sub(entry.ChangedAt))
+		if i < len(entries)-1 {
+			lasted = formatDuration(entries[i+1].ChangedAt.Sub(entry.ChangedAt))
+		}
+
+		when := entry.ChangedAt.Local().Format("Mon Jan 2 15:04:05") + "  "
+		change := fmt.Sprintf("%s → %s", from, entry.To)
+		lines = append(lines, when+getStatusColor(entry.To).Sprint(pad(change, 32))+gray.Sprint(" "+lasted))
+	}
+	return lines
+}
+
+// draw writes the screen for d to w in one write, overwriting the previous frame
+func (t *tui) draw(w io.Writer, d tuiData) {
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	for i, line := range t.render(d) {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(line)
+		b.WriteString("\x1b[K")
+	}
+	b.WriteString("\x1b[J")
+	io.WriteString(w, b.String())
+}
+
+// resize reads the terminal size, keeping the last known size if it can't be read
+func (t *tui) resize() {
+	if width, height, err := terminalSize(os.Stdout); err == nil && width > 0 && height > 0 {
+		t.width, t.height = width, height
+	}
+}
+
+// readInput sends each read from f on input until f is closed
+func readInput(f *os.File, input chan<- []byte) {
+	buf := make([]byte, 64)
+	for {
+		n, err := f.Read(buf)
+		if err != nil {
+			close(input)
+			return
+		}
+		input <- slices.Clone(buf[:n])
+	}
+}
+
+// runTUI shows the full-screen watch display until the user quits or ctx is done, returning
+// the exit code. The terminal is restored either way. Like the plain display it only reads
+// the cache: the fetcher goroutine writes it, changed receives whenever the cache changes,
+// and a send on refresh asks the fetcher to fetch now. It redraws on those changes, keypresses
+// and terminal resizes, and every tuiClockInterval so ages and acks don't go stale.
+func runTUI(ctx context.Context, cache *StatusCache, refresh chan<- struct{}, changed <-chan struct{}) int {
+	restore, err := enableRawMode(os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+	defer restore()
+	fmt.Print(enterFullScreen)
+	defer fmt.Print(exitFullScreen)
+
+	input := make(chan []byte)
+	go readInput(os.Stdin, input)
+	resized, stopResize := notifyResize(os.Stdout)
+	defer stopResize()
+	data := loadTUIData(cache)
+	// The cache is only re-read when it changes, but what's shown relative to now still ages
+	clock := time.NewTicker(tuiClockInterval)
+	defer clock.Stop()
+
+	t := newTUI(data.cfg.RegionIDs())
+	t.resize()
+	for {
+		t.draw(os.Stdout, data)
+
+		select {
+		case keys, ok := <-input:
+			if !ok {
+				return exitOK
+			}
+			for _, key := range parseKeys(keys) {
+				switch t.handleKey(key) {
+				case tuiQuit:
+					return exitOK
+				case tuiRefresh:
+					select {
+					case refresh <- struct{}{}:
+					default: // A refresh is already pending
+					}
+				}
+			}
+		case <-resized:
+			t.resize()
+		case <-changed:
+			t.message = ""
+			data = loadTUIData(cache)
+			t.setRegions(data.cfg.RegionIDs()) // The config may have been reloaded
+		case now := <-clock.C:
+			data = data.at(now)
+		case <-ctx.Done():
+			return exitOK
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// withoutColor disables color output for the rest of the test
+func withoutColor(t *testing.T) {
+	t.Helper()
+	original := color.NoColor
+	color.NoColor = true
+	t.Cleanup(func() { color.NoColor = original })
+}
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"q", []string{"q"}},
+		{"jk", []string{"j", "k"}},
+		{"\x1b[A\x1b[B", []string{keyUp, keyDown}},
+		{"\x1bOA", []string{keyUp}},
+		{"\x1b[1;5B", []string{keyDown}},
+		{"\x1b[C", nil},
+		{"\x1b", []string{keyEscape}},
+		{"\r", []string{keyEnter}},
+		{"\x03", []string{keyCtrlC}},
+		{"3\x7f", []string{"3"}},
+	}
+
+	for _, tt := range tests {
+		if got := parseKeys([]byte(tt.input)); !slices.Equal(got, tt.expected) {
+			t.Errorf("parseKeys(%q) = %q, expected %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestTUI_Navigation(t *testing.T) {
+	ui := newTUI([]string{"au", "us", "eu"})
+
+	ui.handleKey(keyUp)
+	if region, _ := ui.selectedRegion(); region != "au" {
+		t.Errorf("expected selection to stop at the top, got %s", region)
+	}
+	for _, key := range []string{keyDown, "j", "j"} {
+		ui.handleKey(key)
+	}
+	if region, _ := ui.selectedRegion(); region != "eu" {
+		t.Errorf("expected selection to stop at the bottom, got %s", region)
+	}
+	ui.handleKey("k")
+	if region, _ := ui.selectedRegion(); region != "us" {
+		t.Errorf("expected k to move up, got %s", region)
+	}
+}
+
+func TestTUI_ToggleRegions(t *testing.T) {
+	ui := newTUI([]string{"au", "us", "eu"})
+	ui.selected = 2
+
+	ui.handleKey("3")
+	if visible := ui.visible(); !slices.Equal(visible, []string{"au", "us"}) {
+		t.Errorf("expected eu to be hidden, got %v", visible)
+	}
+	if region, _ := ui.selectedRegion(); region != "us" {
+		t.Errorf("expected selection to move to the last visible region, got %s", region)
+	}
+
+	ui.handleKey("1")
+	ui.handleKey("9") // No ninth region
+	if visible := ui.visible(); !slices.Equal(visible, []string{"us"}) {
+		t.Errorf("expected only us visible, got %v", visible)
+	}
+
+	ui.handleKey("1")
+	if visible := ui.visible(); !slices.Equal(visible, []string{"au", "us"}) {
+		t.Errorf("expected toggling again to show au, got %v", visible)
+	}
+
+	ui.handleKey("a")
+	if visible := ui.visible(); len(visible) != 3 {
+		t.Errorf("expected a to show every region, got %v", visible)
+	}
+}
+
+func TestTUI_HistoryAndQuit(t *testing.T) {
+	ui := newTUI([]string{"au", "us"})
+
+	ui.handleKey(keyDown)
+	ui.handleKey(keyEnter)
+	if ui.view != tuiHistoryView {
+		t.Fatal("expected enter to open the history")
+	}
+	ui.handleKey(keyDown) // Doesn't move the selection while showing history
+	if region, _ := ui.selectedRegion(); region != "us" {
+		t.Errorf("expected history for us, got %s", region)
+	}
+	ui.handleKey(keyEscape)
+	if ui.view != tuiTableView {
+		t.Error("expected esc to go back to the table")
+	}
+
+	if action := ui.handleKey("r"); action != tuiRefresh || ui.message == "" {
+		t.Errorf("expected r to refresh with a message, got %v %q", action, ui.message)
+	}
+	if ui.handleKey("q") != tuiQuit || ui.handleKey(keyCtrlC) != tuiQuit {
+		t.Error("expected q and ctrl-c to quit")
+	}
+
+	// Every region hidden: nothing to open
+	ui.handleKey("1")
+	ui.handleKey("2")
+	if ui.handleKey("h"); ui.view != tuiTableView {
+		t.Error("expected history not to open without a visible region")
+	}
+}
+
+func TestTUI_SetRegions(t *testing.T) {
+	ui := newTUI([]string{"au", "us", "eu"})
+	ui.handleKey("2")
+	ui.selected = 1
+
+	ui.setRegions([]string{"au", "us"}) // eu removed from the config
+	if visible := ui.visible(); !slices.Equal(visible, []string{"au"}) {
+		t.Errorf("expected us to stay hidden, got %v", visible)
+	}
+	if region, _ := ui.selectedRegion(); region != "au" {
+		t.Errorf("expected the selection to stay on a visible region, got %s", region)
+	}
+}
+
+func TestTimelineStatuses(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	entries := []transition{
+		{Region: "au", To: "testing", ChangedAt: now.Add(-270 * time.Minute)},
+		{Region: "us", To: "testfail", ChangedAt: now.Add(-4 * time.Hour)},
+		{Region: "au", To: "complete", ChangedAt: now.Add(-150 * time.Minute)},
+	}
+
+	got := timelineStatuses(entries, "au", now, 6*time.Hour, 6)
+	expected := []string{"", "testing", "testing", "complete", "complete", "complete"}
+	if !slices.Equal(got, expected) {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+
+	if got := timelineStatuses(nil, "au", now, 6*time.Hour, 3); !slices.Equal(got, []string{"", "", ""}) {
+		t.Errorf("expected an empty timeline without history, got %q", got)
+	}
+}
+
+func TestTimelineBars(t *testing.T) {
+	withoutColor(t)
+
+	if got := timelineBars([]string{"", "pr", "testing", "testfail", "complete"}); got != "·▄▆█▁" {
+		t.Errorf("unexpected bars %q", got)
+	}
+}
+
+func TestFitAndPad(t *testing.T) {
+	if got := fit("Australia", 5); got != "Aust…" {
+		t.Errorf("fit = %q", got)
+	}
+	if got := fit("AU", 5); got != "AU" {
+		t.Errorf("fit = %q", got)
+	}
+	if got := fit("AU", 0); got != "" {
+		t.Errorf("fit = %q", got)
+	}
+	if got := pad("AU", 4); got != "AU  " {
+		t.Errorf("pad = %q", got)
+	}
+	if got := pad("→ testing", 5); got != "→ te…" {
+		t.Errorf("pad = %q", got)
+	}
+}
+
+func TestFormatAge(t *testing.T) {
+	tests := []struct {
+		d        time.Duration
+		expected string
+	}{
+		{30 * time.Second, "<1m"},
+		{12 * time.Minute, "12m"},
+		{5 * time.Hour, "5h"},
+		{72 * time.Hour, "3d"},
+	}
+	for _, tt := range tests {
+		if got := formatAge(tt.d); got != tt.expected {
+			t.Errorf("formatAge(%v) = %q, expected %q", tt.d, got, tt.expected)
+		}
+	}
+}
+
+// testTUIData returns display data with au deploying, us failing to fetch and eu acknowledged
+func testTUIData(now time.Time) tuiData {
+	return tuiData{
+		cfg: currentConfig(),
+		statuses: map[string]statusResult{
+			"au": {region: "au", status: "testing"},
+			"us": {region: "us", err: errors.New("timeout")},
+			"eu": {region: "eu", status: "testfail"},
+		},
+		history: []transition{
+			{Region: "au", To: "pr", ChangedAt: now.Add(-3 * time.Hour)},
+			{Region: "au", From: "pr", To: "testing", ChangedAt: now.Add(-20 * time.Minute)},
+		},
+		acks:     map[string]silence{"eu": {Region: "eu", CreatedBy: "alice", ExpiresAt: now.Add(time.Hour)}},
+		silenced: map[string]bool{},
+		now:      now,
+	}
+}
+
+func TestTUI_RenderTable(t *testing.T) {
+	withoutColor(t)
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.Local)
+	ui := newTUI([]string{"au", "us", "eu"})
+	ui.width, ui.height = 100, 20
+
+	lines := ui.render(testTUIData(now))
+	if len(lines) != ui.height {
+		t.Fatalf("expected %d lines, got %d", ui.height, len(lines))
+	}
+	screen := strings.Join(lines, "\n")
+
+	if !strings.Contains(lines[3], "> 1 "+regionLabel("au")) || !strings.Contains(lines[3], "testing") || !strings.Contains(lines[3], "20m") {
+		t.Errorf("expected the selected au row with its status and age, got %q", lines[3])
+	}
+	if !strings.Contains(screen, "timeout") {
+		t.Error("expected the fetch error to be shown")
+	}
+	if !strings.Contains(screen, "acked by alice") {
+		t.Error("expected the acknowledgement to be shown")
+	}
+	if !strings.Contains(screen, "LAST 12 HOURS") || !strings.Contains(lines[3], "▄") {
+		t.Errorf("expected a timeline for au, got %q", lines[3])
+	}
+	for _, line := range lines {
+		if n := len([]rune(line)); n > ui.width {
+			t.Errorf("line wider than the terminal (%d): %q", n, line)
+		}
+	}
+	if footer := lines[len(lines)-1]; !strings.Contains(footer, "q quit") {
+		t.Errorf("expected help in the footer, got %q", footer)
+	}
+
+	// The footer shows the cache's freshness even in panes that never write it
+	d := testTUIData(now)
+	d.lastRead, d.lastWrite = now, now.Add(-30*time.Second)
+	if footer := ui.render(d)[ui.height-1]; !strings.Contains(footer, "read 12:00:00  written 11:59:30") {
+		t.Errorf("expected the last read and write in the footer, got %q", footer)
+	}
+
+	// Narrow terminals drop the timeline and hidden regions are listed
+	ui.width = 45
+	ui.handleKey("2")
+	screen = strings.Join(ui.render(testTUIData(now)), "\n")
+	if strings.Contains(screen, "LAST") {
+		t.Error("expected no timeline on a narrow terminal")
+	}
+	if !strings.Contains(screen, "hidden: 2 "+regionLabel("us")) || strings.Contains(screen, "timeout") {
+		t.Errorf("expected us to be hidden, got:\n%s", screen)
+	}
+}
+
+func TestTUIData_At(t *testing.T) {
+	withoutColor(t)
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.Local)
+	ui := newTUI([]string{"au", "us", "eu"})
+	ui.width, ui.height = 100, 20
+
+	// An hour on, without re-reading the cache, ages have moved on and the ack has expired
+	screen := strings.Join(ui.render(testTUIData(now).at(now.Add(time.Hour))), "\n")
+	if lines := strings.Split(screen, "\n"); !strings.Contains(lines[3], "1h") {
+		t.Errorf("expected au's age to have moved on, got %q", lines[3])
+	}
+	if strings.Contains(screen, "acked by alice") {
+		t.Errorf("expected the expired acknowledgement to be gone, got:\n%s", screen)
+	}
+}
+
+func TestTUI_RenderHistory(t *testing.T) {
+	withoutColor(t)
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.Local)
+	ui := newTUI([]string{"au", "us"})
+	ui.width, ui.height = 100, 10
+	ui.handleKey(keyEnter)
+
+	lines := ui.render(testTUIData(now))
+	if !strings.Contains(lines[0], regionLabel("au")+" history") {
+		t.Errorf("expected a title, got %q", lines[0])
+	}
+	if !strings.Contains(lines[2], "pr → testing") || !strings.Contains(lines[2], "current, 20m") {
+		t.Errorf("expected the newest transition first, got %q", lines[2])
+	}
+	if !strings.Contains(lines[3], "(first seen) → pr") || !strings.Contains(lines[3], "2h40m") {
+		t.Errorf("expected the first transition with how long it lasted, got %q", lines[3])
+	}
+
+	ui.handleKey(keyEscape)
+	ui.handleKey(keyDown)
+	ui.handleKey(keyEnter)
+	if screen := strings.Join(ui.render(testTUIData(now)), "\n"); !strings.Contains(screen, "No status transitions recorded") {
+		t.Errorf("expected an empty history for us, got:\n%s", screen)
+	}
+}
+
+func TestTUI_RenderClipsToHeight(t *testing.T) {
+	withoutColor(t)
+	ui := newTUI([]string{"au", "us", "eu"})
+	ui.width, ui.height = 80, 4
+
+	lines := ui.render(testTUIData(time.Now()))
+	if len(lines) != 4 || !strings.Contains(lines[3], "q quit") {
+		t.Errorf("expected 3 lines of table and the footer, got %q", lines)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// waiter polls statuses until the selected regions are complete or one of them fails
+type waiter struct {
+	cache         *StatusCache
+	regions       []string
+	out           io.Writer
+	fetch         func(context.Context, *StatusCache)
+	interval      func(*StatusCache) time.Duration
+	now           func() time.Time
+	requireChange bool              // don't finish until a region has left complete
+	started       bool              // a selected region was seen in progress
+	announced     bool              // "Waiting for a deployment to start" was printed
+	last          map[string]string // last value printed per region
+}
+
+// run blocks until the deployment finishes, fails, or ctx is done, returning the exit code
+func (w *waiter) run(ctx context.Context) int {
+	if w.last == nil {
+		w.last = make(map[string]string)
+	}
+
+	for {
+		w.fetch(ctx, w.cache)
+		if ctx.Err() != nil {
+			// A cut-short fetch leaves the cache as it was, possibly complete from the last deployment
+			fmt.Fprintln(w.out, "Timed out waiting for deployment")
+			return exitTimeout
+		}
+		statuses := w.cache.GetAll()
+		w.printTransitions(statuses)
+
+		acked := acknowledgedRegions(w.cache)
+		done := true
+		for _, region := range w.regions {
+			if _, ok := acked[region]; ok {
+				continue // Acknowledged regions neither fail nor hold up the wait
+			}
+			switch regionState(statuses[region]) {
+			case stateFailed:
+				fmt.Fprintf(w.out, "Deployment failed: %s is %s\n", regionLabel(region), statuses[region].status)
+				return exitFailed
+			case stateComplete:
+			case stateInProgress:
+				w.started = true
+				done = false
+			default:
+				done = false
+			}
+		}
+		if done && w.requireChange && !w.started {
+			// Still complete from the previous deployment, so wait for the next one to start
+			if !w.announced {
+				fmt.Fprintln(w.out, "Waiting for a deployment to start")
+				w.announced = true
+			}
+			done = false
+		}
+		if done {
+			fmt.Fprintln(w.out, "Deployment complete")
+			return exitOK
+		}
+
+		if !sleepContext(ctx, w.interval(w.cache)) {
+			fmt.Fprintln(w.out, "Timed out waiting for deployment")
+			return exitTimeout
+		}
+	}
+}
+
+// printTransitions prints a line for each selected region whose value changed since the last check
+func (w *waiter) printTransitions(statuses map[string]statusResult) {
+	timestamp := w.now().Format("15:04:05")
+	for _, region := range w.regions {
+		result := statuses[region]
+		value := result.status
+		if result.err != nil {
+			value = result.err.Error()
+		}
+
+		previous, seen := w.last[region]
+		if seen && previous == value {
+			continue
+		}
+		w.last[region] = value
+
+		if seen {
+			fmt.Fprintf(w.out, "%s %-10s %s -> %s\n", timestamp, regionLabel(region), previous, value)
+		} else {
+			fmt.Fprintf(w.out, "%s %-10s %s\n", timestamp, regionLabel(region), value)
+		}
+	}
+}
+
+// parseRegions parses a comma-separated list of region IDs, checking each is configured in cfg
+func parseRegions(cfg *Config, value string) ([]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return cfg.RegionIDs(), nil
+	}
+
+	var selected []string
+	for _, part := range strings.Split(value, ",") {
+		region := strings.ToLower(strings.TrimSpace(part))
+		if _, ok := cfg.Region(region); !ok {
+			return nil, fmt.Errorf("unknown region %q (expected one of %s)", region, strings.Join(cfg.RegionIDs(), ", "))
+		}
+		selected = append(selected, region)
+	}
+	return selected, nil
+}
+
+// runWait implements the wait subcommand
+func runWait(args []string) int {
+	fs := flag.NewFlagSet("wait", flag.ContinueOnError)
+	configPath := fs.String("config", "", "Path to config file (default: user config dir)")
+	timeout := fs.Duration("timeout", 0, "Give up after this long, e.g. 45m (default: wait forever)")
+	regionsFlag := fs.String("regions", "", "Comma-separated regions to wait on (default: all)")
+	requireChange := fs.Bool("require-change", false, "Wait for a new deployment to start first, instead of exiting if everything is already complete")
+	intervals := addPollFlags(fs, false)
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	cache, ok := initialize(*configPath)
+	if !ok {
+		return exitError
+	}
+	if err := intervals.apply(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+
+	selected, err := parseRegions(currentConfig(), *regionsFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitUsage
+	}
+
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	schedule := newScheduler(currentConfig().Poll)
+	w := &waiter{
+		cache:         cache,
+		regions:       selected,
+		out:           os.Stdout,
+		fetch:         fetchAllStatuses,
+		interval:      func(c *StatusCache) time.Duration { return schedule.next(c.GetAll()) },
+		now:           time.Now,
+		requireChange: *requireChange,
+	}
+	return w.run(ctx)
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scriptedFetch returns a fetch function that applies one set of statuses per call,
+// repeating the last set once the script runs out
+func scriptedFetch(script []map[string]string) func(context.Context, *StatusCache) {
+	calls := 0
+	return func(_ context.Context, cache *StatusCache) {
+		step := script[min(calls, len(script)-1)]
+		calls++
+
+		results := make(map[string]statusResult, len(step))
+		for region, status := range step {
+			results[region] = statusResult{region: region, status: status}
+		}
+		cache.UpdateAll(results)
+	}
+}
+
+func newTestWaiter(t *testing.T, selected []string, script []map[string]string) (*waiter, *bytes.Buffer) {
+	t.Helper()
+	var out bytes.Buffer
+	return &waiter{
+		cache:    NewStatusCacheWithPath(filepath.Join(t.TempDir(), "statuses.json")),
+		regions:  selected,
+		out:      &out,
+		fetch:    scriptedFetch(script),
+		interval: func(*StatusCache) time.Duration { return time.Millisecond },
+		now:      time.Now,
+	}, &out
+}
+
+func TestWaiter_CompletesWhenAllRegionsComplete(t *testing.T) {
+	w, out := newTestWaiter(t, []string{"overall", "au"}, []map[string]string{
+		{"overall": "testing", "au": "building"},
+		{"overall": "deploy", "au": "complete"},
+		{"overall": "complete", "au": "complete"},
+	})
+
+	if code := w.run(context.Background()); code != exitOK {
+		t.Errorf("expected exit code %d, got %d", exitOK, code)
+	}
+
+	output := out.String()
+	for _, expected := range []string{"testing -> deploy", "building -> complete", "deploy -> complete", "Deployment complete"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected output to contain %q, got:\n%s", expected, output)
+		}
+	}
+}
+
+func TestWaiter_AlreadyCompleteExitsUnlessChangeRequired(t *testing.T) {
+	script := []map[string]string{
+		{"overall": "complete"},
+		{"overall": "complete"},
+		{"overall": "testing"},
+		{"overall": "complete"},
+	}
+
+	w, out := newTestWaiter(t, []string{"overall"}, script)
+	if code := w.run(context.Background()); code != exitOK {
+		t.Errorf("expected exit code %d, got %d", exitOK, code)
+	}
+	if strings.Contains(out.String(), "testing") {
+		t.Errorf("expected to exit on the first complete poll, got:\n%s", out)
+	}
+
+	w, out = newTestWaiter(t, []string{"overall"}, script)
+	w.requireChange = true
+	if code := w.run(context.Background()); code != exitOK {
+		t.Errorf("expected exit code %d, got %d", exitOK, code)
+	}
+	output := out.String()
+	if strings.Count(output, "Waiting for a deployment to start") != 1 {
+		t.Errorf("expected one note while waiting for the deployment to start, got:\n%s", output)
+	}
+	if !strings.Contains(output, "testing -> complete") || !strings.HasSuffix(output, "Deployment complete\n") {
+		t.Errorf("expected to wait for the new deployment to finish, got:\n%s", output)
+	}
+}
+
+func TestWaiter_FailsImmediatelyOnTestfail(t *testing.T) {
+	w, out := newTestWaiter(t, []string{"overall", "au"}, []map[string]string{
+		{"overall": "testing", "au": "testing"},
+		{"overall": "testfail", "au": "testing"},
+		{"overall": "complete", "au": "complete"},
+	})
+
+	if code := w.run(context.Background()); code != exitFailed {
+		t.Errorf("expected exit code %d, got %d", exitFailed, code)
+	}
+	if !strings.Contains(out.String(), "Deployment failed: Status is testfail") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+}
+
+func TestWaiter_OnlyWaitsOnSelectedRegions(t *testing.T) {
+	w, _ := newTestWaiter(t, []string{"au"}, []map[string]string{
+		{"overall": "testing", "au": "complete", "ca": "error"},
+	})
+
+	if code := w.run(context.Background()); code != exitOK {
+		t.Errorf("expected exit code %d, got %d", exitOK, code)
+	}
+}
+
+func TestWaiter_TimesOut(t *testing.T) {
+	w, out := newTestWaiter(t, []string{"overall"}, []map[string]string{
+		{"overall": "testing"},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if code := w.run(ctx); code != exitTimeout {
+		t.Errorf("expected exit code %d, got %d", exitTimeout, code)
+	}
+	if strings.Count(out.String(), "testing") != 1 {
+		t.Errorf("expected unchanged status to be printed once, got:\n%s", out.String())
+	}
+}
+
+func TestWaiter_TimesOutDuringFetch(t *testing.T) {
+	w, out := newTestWaiter(t, []string{"overall"}, nil)
+	w.cache.UpdateAll(testStatuses("overall", "complete")) // From the previous deployment
+	w.fetch = func(ctx context.Context, _ *StatusCache) {
+		<-ctx.Done() // Gives up without updating the cache
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if code := w.run(ctx); code != exitTimeout {
+		t.Errorf("expected exit code %d, got %d", exitTimeout, code)
+	}
+	if strings.Contains(out.String(), "Deployment complete") {
+		t.Errorf("expected the stale statuses not to be judged, got:\n%s", out.String())
+	}
+}
+
+func TestParseRegions(t *testing.T) {
+	selected, err := parseRegions(currentConfig(), "")
+	if err != nil || len(selected) != len(currentConfig().Regions) {
+		t.Errorf("expected all regions by default, got %v (err %v)", selected, err)
+	}
+
+	selected, err = parseRegions(currentConfig(), "AU, ca")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(selected) != 2 || selected[0] != "au" || selected[1] != "ca" {
+		t.Errorf("unexpected regions: %v", selected)
+	}
+
+	if _, err := parseRegions(currentConfig(), "au,eu"); err == nil {
+		t.Error("expected error for unknown region")
+	}
+}
+
+func TestWaiter_IgnoresAcknowledgedRegions(t *testing.T) {
+	w, _ := newTestWaiter(t, []string{"overall", "au"}, []map[string]string{
+		{"overall": "testing", "au": "testfail"},
+		{"overall": "complete", "au": "testfail"},
+	})
+	w.cache.Silences().Add(silence{Region: "au", ExpiresAt: time.Now().Add(time.Hour)})
+
+	if code := w.run(context.Background()); code != exitOK {
+		t.Errorf("expected the acknowledged failure to be ignored, got exit code %d", code)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// watchOptions are the root flags used by watch mode
+type watchOptions struct {
+	configPath string
+	output     string
+	textfile   string
+	onChange   string
+	intervals  pollFlags
+}
+
+// runWatch shows the statuses until ctx is done or the user quits, returning the exit code.
+// Fetching (write logic) runs in the background and the display (read logic) only reads the
+// cache. On the way out, fetching is cancelled and waited for, so the cache and history are
+// never left half-updated, and the lease is released for another process to take over.
+// Queued webhook deliveries and hook runs are then finished, for up to shutdownGrace.
+func runWatch(ctx context.Context, cache *StatusCache, opts watchOptions) int {
+	f := newFetcher(cache)
+
+	// Deferred first, so webhooks, hooks and Slack posts stop once fetching has
+	alerts, stopNotifiers, err := startNotifiers(cache, f.lease, opts.onChange)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitError
+	}
+	defer stopNotifiers()
+
+	// The display redraws as soon as the cache changes, by this process or another
+	watcher := newCacheWatcher(cache)
+	go watcher.run(ctx.Done())
+
+	// Initial fetch before displaying, if this process is the fetcher
+	wait := f.tick(ctx)
+
+	// A send on refresh fetches right away if this process holds the lease, and the display
+	// is told once it's done even if there was nothing to fetch. SIGHUP reloads the config.
+	refresh := make(chan struct{}, 1)
+	reload, stopReload := notifyReload()
+	defer stopReload()
+
+	fetchCtx, stopFetching := context.WithCancel(ctx)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		f.run(fetchCtx, wait, fetchLoop{
+			refresh:  refresh,
+			reload:   reload,
+			onReload: configReloader(opts.configPath, opts.intervals, f, alerts),
+			afterTick: func(forced, fetched bool) {
+				if opts.textfile != "" {
+					if err := exportMetrics(cache, opts.textfile); err != nil {
+						fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					}
+				}
+				// A fetch already told the display through OnUpdate
+				if forced && !fetched {
+					watcher.notify()
+				}
+			},
+		})
+	}()
+	defer func() {
+		stopFetching()
+		<-stopped
+	}()
+
+	// Full-screen display when run interactively
+	if opts.output == outputText && isTerminal(os.Stdin) && isTerminal(os.Stdout) {
+		return runTUI(ctx, cache, refresh, watcher.Changed())
+	}
+
+	// Otherwise print the statuses when the cache changes, and at least every display interval
+	for {
+		cache.Reload()
+		if opts.output == outputText {
+			clearScreen()
+			printStatus(cache, true)
+		} else {
+			writeSnapshot(os.Stdout, cache, opts.output)
+		}
+		select {
+		case <-watcher.Changed():
+		case <-time.After(currentConfig().Poll.Display):
+		case <-ctx.Done():
+			return exitOK
+		}
+	}
+}
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Webhook body formats
+const (
+	webhookFormatJSON  = "json"
+	webhookFormatSlack = "slack"
+)
+
+// WebhookConfig describes an endpoint that is sent each status transition and alert
+type WebhookConfig struct {
+	Name     string            `toml:"name"`     // shown in the delivery log, defaults to the URL's host
+	URL      string            `toml:"url"`      // endpoint each transition is POSTed to
+	Format   string            `toml:"format"`   // "json" (default) or "slack"
+	Template string            `toml:"template"` // text/template for the body, replacing format
+	Regions  []string          `toml:"regions"`  // only send these regions, default all
+	Headers  map[string]string `toml:"headers"`  // extra request headers, e.g. Authorization
+}
+
+// webhookRetryPolicy controls redelivery of failed webhook requests. Deliveries run in the
+// background, so they can keep retrying for longer than fetches.
+var webhookRetryPolicy = RetryPolicy{
+	Attempts:   5,
+	Backoff:    2 * time.Second,
+	MaxBackoff: 1 * time.Minute,
+	Deadline:   5 * time.Minute,
+}
+
+// Webhook delivery limits
+const (
+	webhookQueueSize       = 100 // events queued per webhook before new ones are dropped
+	webhookResponseSnippet = 512
+)
+
+// validateWebhook checks a webhook config and fills in its defaults
+func validateWebhook(w *WebhookConfig, known func(string) bool) error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook url %q must be an http or https URL", w.URL)
+	}
+	if w.Name == "" {
+		w.Name = u.Host
+	}
+
+	switch w.Format {
+	case "":
+		w.Format = webhookFormatJSON
+	case webhookFormatJSON, webhookFormatSlack:
+	default:
+		return fmt.Errorf("webhook %q: unknown format %q (expected json or slack)", w.Name, w.Format)
+	}
+
+	if w.Template != "" {
+		if _, err := parseWebhookTemplate(w.Template); err != nil {
+			return fmt.Errorf("webhook %q: invalid template: %w", w.Name, err)
+		}
+	}
+
+	for _, region := range w.Regions {
+		if !known(region) {
+			return fmt.Errorf("webhook %q: unknown region %q", w.Name, region)
+		}
+	}
+	return nil
+}
+
+// webhookPayload is the body sent in the json format, and the data passed to templates
+type webhookPayload struct {
+	Event     string    `json:"event"` // "transition" or "alert"
+	ID        int64     `json:"id,omitempty"`
+	Region    string    `json:"region"`
+	Label     string    `json:"label"`
+	From      string    `json:"from,omitempty"`
+	To        string    `json:"to,omitempty"`
+	Alert     string    `json:"alert,omitempty"` // rule name, for alerts
+	State     string    `json:"state,omitempty"` // "firing" or "resolved", for alerts
+	Class     string    `json:"class"`
+	ChangedAt time.Time `json:"changedAt"`
+	Text      string    `json:"text"` // human-readable summary, e.g. "AU: testing → testok"
+}
+
+// newWebhookPayload describes a transition for webhook bodies
+func newWebhookPayload(entry transition) webhookPayload {
+	label := regionLabel(entry.Region)
+	return webhookPayload{
+		Event:     "transition",
+		ID:        entry.ID,
+		Region:    entry.Region,
+		Label:     label,
+		From:      entry.From,
+		To:        entry.To,
+		Class:     classifyStatus(entry.To),
+		ChangedAt: entry.ChangedAt,
+		Text:      fmt.Sprintf("%s: %s → %s", label, entry.From, entry.To),
+	}
+}
+
+// newAlertPayload describes an alert firing or resolving for webhook bodies. Its class is
+// red while firing and green once resolved.
+func newAlertPayload(ev alertEvent) webhookPayload {
+	class := classRed
+	if ev.State == alertResolved {
+		class = classGreen
+	}
+	return webhookPayload{
+		Event:     "alert",
+		Region:    ev.Region,
+		Label:     regionLabel(ev.Region),
+		Alert:     ev.Rule,
+		State:     ev.State,
+		Class:     class,
+		ChangedAt: ev.At,
+		Text:      ev.Text(),
+	}
+}
+
+// webhookEvent is a queued webhook notification, rendered for each body format
+type webhookEvent struct {
+	payload webhookPayload
+	slack   slackMessage
+}
+
+// transitionEvent returns the webhook event for a status transition
+func transitionEvent(entry transition) webhookEvent {
+	return webhookEvent{payload: newWebhookPayload(entry), slack: renderSlackTransition(entry)}
+}
+
+// alertWebhookEvent returns the webhook event for an alert firing or resolving
+func alertWebhookEvent(ev alertEvent) webhookEvent {
+	return webhookEvent{payload: newAlertPayload(ev), slack: renderSlackAlert(ev)}
+}
+
+// parseWebhookTemplate parses a body template. The json function encodes a value as JSON,
+// so strings can be embedded safely: {"text": {{json .Text}}}
+func parseWebhookTemplate(text string) (*template.Template, error) {
+	return template.New("webhook").Option("missingkey=error").Funcs(template.FuncMap{
+		"json": func(v any) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+	}).Parse(text)
+}
+
+// webhookTarget is a configured webhook with its own delivery queue
+type webhookTarget struct {
+	config   WebhookConfig
+	template *template.Template
+	queue    chan webhookEvent
+}
+
+// body renders the request body for an event
+func (t *webhookTarget) body(ev webhookEvent) ([]byte, error) {
+	if t.template != nil {
+		var buf bytes.Buffer
+		if err := t.template.Execute(&buf, ev.payload); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	if t.config.Format == webhookFormatSlack {
+		return json.Marshal(ev.slack)
+	}
+	return json.Marshal(ev.payload)
+}
+
+// wants reports whether the webhook should receive events for region
+func (t *webhookTarget) wants(region string) bool {
+	return len(t.config.Regions) == 0 || slices.Contains(t.config.Regions, region)
+}
+
+// delivery is a single attempt to send an event to a webhook, as written to the delivery log
+type delivery struct {
+	Webhook    string    `json:"webhook"`
+	Transition int64     `json:"transition,omitempty"`
+	Alert      string    `json:"alert,omitempty"`
+	Region     string    `json:"region"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Delivered  bool      `json:"delivered"`
+	At         time.Time `json:"at"`
+	Duration   float64   `json:"durationSeconds"`
+}
+
+// webhookDispatcher sends transitions and alerts to the configured webhooks in the
+// background. Each webhook has its own queue, so a slow endpoint doesn't hold up the others
+// and each endpoint receives events in order.
+type webhookDispatcher struct {
+	targets []*webhookTarget
+	log     *jsonLinesLog
+	policy  RetryPolicy
+}
+
+// newWebhookDispatcher creates a dispatcher for configs, logging deliveries to logPath
+func newWebhookDispatcher(configs []WebhookConfig, logPath string) (*webhookDispatcher, error) {
+	d := &webhookDispatcher{log: newJSONLinesLog(logPath, currentConfig().History), policy: webhookRetryPolicy}
+	for _, config := range configs {
+		target := &webhookTarget{config: config, queue: make(chan webhookEvent, webhookQueueSize)}
+		if config.Template != "" {
+			tmpl, err := parseWebhookTemplate(config.Template)
+			if err != nil {
+				return nil, fmt.Errorf("webhook %q: invalid template: %w", config.Name, err)
+			}
+			target.template = tmpl
+		}
+		d.targets = append(d.targets, target)
+	}
+	return d, nil
+}
+
+// enqueue queues the transitions that are changes for delivery without blocking
+func (d *webhookDispatcher) enqueue(entries []transition) {
+	for _, entry := range entries {
+		if entry.From != "" {
+			d.send(transitionEvent(entry))
+		}
+	}
+}
+
+// enqueueAlert queues an alert firing or resolving for delivery without blocking
+func (d *webhookDispatcher) enqueueAlert(ev alertEvent) {
+	d.send(alertWebhookEvent(ev))
+}
+
+// send queues ev for each webhook that wants its region, logging it as dropped for
+// webhooks whose queue is full
+func (d *webhookDispatcher) send(ev webhookEvent) {
+	for _, target := range d.targets {
+		if !target.wants(ev.payload.Region) {
+			continue
+		}
+		select {
+		case target.queue <- ev:
+		default:
+			d.drop(target, ev, "delivery queue full")
+		}
+	}
+}
+
+// drop logs ev as not sent to target, and why
+func (d *webhookDispatcher) drop(target *webhookTarget, ev webhookEvent, reason string) {
+	result := newDelivery(target, ev)
+	result.Error = fmt.Sprintf("%s, %s dropped", reason, ev.payload.Event)
+	result.At = time.Now()
+	d.record(result)
+}
+
+// newDelivery starts a delivery log entry for sending ev to target
+func newDelivery(target *webhookTarget, ev webhookEvent) delivery {
+	return delivery{
+		Webhook:    target.config.Name,
+		Transition: ev.payload.ID,
+		Alert:      ev.payload.Alert,
+		Region:     ev.payload.Region,
+	}
+}
+
+// run delivers queued events until ctx is done, then delivers the events still queued for up
+// to shutdownGrace, logging any left after that as dropped
+func (d *webhookDispatcher) run(ctx context.Context) {
+	drainCtx, cancel := graceContext(ctx, shutdownGrace)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, target := range d.targets {
+		wg.Add(1)
+		go func(target *webhookTarget) {
+			defer wg.Done()
+			for {
+				select {
+				case ev := <-target.queue:
+					d.deliver(drainCtx, target, ev)
+				case <-ctx.Done():
+					d.drain(drainCtx, target)
+					return
+				}
+			}
+		}(target)
+	}
+	wg.Wait()
+}
+
+// drain delivers the events left in target's queue while ctx lasts
+func (d *webhookDispatcher) drain(ctx context.Context, target *webhookTarget) {
+	for {
+		select {
+		case ev := <-target.queue:
+			if ctx.Err() != nil {
+				d.drop(target, ev, "shutting down")
+				continue
+			}
+			d.deliver(ctx, target, ev)
+		default:
+			return
+		}
+	}
+}
+
+// deliver sends ev to target, retrying transient failures per d.policy until ctx is done.
+// Every attempt is written to the delivery log.
+func (d *webhookDispatcher) deliver(ctx context.Context, target *webhookTarget, ev webhookEvent) bool {
+	body, err := target.body(ev)
+	if err != nil {
+		result := newDelivery(target, ev)
+		result.Error = fmt.Sprintf("failed to render body: %v", err)
+		result.At = time.Now()
+		d.record(result)
+		return false
+	}
+
+	if d.policy.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.policy.Deadline)
+		defer cancel()
+	}
+
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		statusCode, err := postWebhook(ctx, target.config, body)
+		result := newDelivery(target, ev)
+		result.Attempt = attempt
+		result.StatusCode = statusCode
+		result.Delivered = err == nil
+		result.At = start
+		result.Duration = time.Since(start).Seconds()
+		if err != nil {
+			result.Error = err.Error()
+		}
+		d.record(result)
+
+		if err == nil {
+			return true
+		}
+		if !isRetryableDelivery(err) || attempt >= d.policy.Attempts {
+			return false
+		}
+		if !sleepContext(ctx, d.policy.delay(attempt)) {
+			return false
+		}
+	}
+}
+
+// record writes a delivery to the log, reporting failures to write it on stderr
+func (d *webhookDispatcher) record(result delivery) {
+	if err := d.log.append(result); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing webhook delivery log: %v\n", err)
+	}
+}
+
+// isRetryableDelivery reports whether a failed webhook request is worth retrying:
+// the same failures as fetches, plus rate limiting
+func isRetryableDelivery(err error) bool {
+	var httpErr *HTTPStatusError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	return isTransient(err)
+}
+
+// postWebhook sends a webhook request, returning the response code if one was received
+func postWebhook(ctx context.Context, config WebhookConfig, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, config.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "deploy-status")
+	for name, value := range config.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseSnippet))
+		return resp.StatusCode, &HTTPStatusError{StatusCode: resp.StatusCode, Snippet: snippet(string(data))}
+	}
+	io.Copy(io.Discard, resp.Body) // Drain so the connection can be reused
+	return resp.StatusCode, nil
+}
+
+// startWebhooks delivers transitions to the configured webhooks in the background, returning
+// the dispatcher for alerts, or nil without webhooks, and a function that waits for the
+// deliveries queued when ctx is done.
+func startWebhooks(ctx context.Context, cache *StatusCache, lease *Lease) (*webhookDispatcher, func(), error) {
+	webhooks := currentConfig().Webhooks
+	if len(webhooks) == 0 {
+		return nil, func() {}, nil
+	}
+	d, err := newWebhookDispatcher(webhooks, filepath.Join(filepath.Dir(cache.filePath), "deliveries.jsonl"))
+	if err != nil {
+		return nil, nil, err
+	}
+	forwarded := followTransitions(ctx, cache, lease.Held, cache.Silences().filterTransitions(d.enqueue))
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		d.run(forwarded)
+	}()
+	return d, func() { <-stopped }, nil
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookReceiver is a local endpoint recording the requests it receives
+type webhookReceiver struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   []string
+	codes    []int // response codes to return in order, then 200
+	server   *httptest.Server
+}
+
+func newWebhookReceiver(t *testing.T, codes ...int) *webhookReceiver {
+	t.Helper()
+	r := &webhookReceiver{codes: codes}
+	r.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+
+		r.mu.Lock()
+		r.requests = append(r.requests, req)
+		r.bodies = append(r.bodies, string(body))
+		code := http.StatusOK
+		if len(r.codes) > 0 {
+			code, r.codes = r.codes[0], r.codes[1:]
+		}
+		r.mu.Unlock()
+
+		w.WriteHeader(code)
+		io.WriteString(w, http.StatusText(code))
+	}))
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+func (r *webhookReceiver) received() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.bodies...)
+}
+
+// waitForBodies waits until the receiver has seen n requests
+func (r *webhookReceiver) waitForBodies(t *testing.T, n int) []string {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); ; time.Sleep(5 * time.Millisecond) {
+		if bodies := r.received(); len(bodies) >= n {
+			return bodies
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d webhook requests, got %d", n, len(r.received()))
+		}
+	}
+}
+
+func newTestDispatcher(t *testing.T, configs ...WebhookConfig) (*webhookDispatcher, string) {
+	t.Helper()
+	for i := range configs {
+		if err := validateWebhook(&configs[i], func(string) bool { return true }); err != nil {
+			t.Fatalf("invalid webhook config: %v", err)
+		}
+	}
+
+	logPath := filepath.Join(t.TempDir(), "deliveries.jsonl")
+	d, err := newWebhookDispatcher(configs, logPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	d.policy = RetryPolicy{Attempts: 3, Backoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond, Deadline: time.Second}
+	return d, logPath
+}
+
+func readDeliveries(t *testing.T, path string) []delivery {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open delivery log: %v", err)
+	}
+	defer f.Close()
+
+	var deliveries []delivery
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var d delivery
+		if err := json.Unmarshal(scanner.Bytes(), &d); err != nil {
+			t.Fatalf("invalid delivery log line %q: %v", scanner.Text(), err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries
+}
+
+var testTransition = transition{
+	ID:        7,
+	Region:    "au",
+	From:      "testing",
+	To:        "testfail",
+	ChangedAt: time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC),
+}
+
+func TestWebhook_JSONFormat(t *testing.T) {
+	receiver := newWebhookReceiver(t)
+	d, _ := newTestDispatcher(t, WebhookConfig{URL: receiver.server.URL})
+
+	if !d.deliver(context.Background(), d.targets[0], transitionEvent(testTransition)) {
+		t.Fatal("expected delivery to succeed")
+	}
+
+	var payload webhookPayload
+	if err := json.Unmarshal([]byte(receiver.received()[0]), &payload); err != nil {
+		t.Fatalf("invalid JSON body: %v", err)
+	}
+	expected := webhookPayload{
+		Event:     "transition",
+		ID:        7,
+		Region:    "au",
+		Label:     "AU",
+		From:      "testing",
+		To:        "testfail",
+		Class:     classRed,
+		ChangedAt: testTransition.ChangedAt,
+		Text:      "AU: testing → testfail",
+	}
+	if payload != expected {
+		t.Errorf("expected %+v, got %+v", expected, payload)
+	}
+	if ct := receiver.requests[0].Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected application/json, got %q", ct)
+	}
+}
+
+func TestWebhook_SlackFormat(t *testing.T) {
+	receiver := newWebhookReceiver(t)
+	d, _ := newTestDispatcher(t, WebhookConfig{URL: receiver.server.URL, Format: webhookFormatSlack})
+
+	d.deliver(context.Background(), d.targets[0], transitionEvent(testTransition))
+
+	var msg slackMessage
+	if err := json.Unmarshal([]byte(receiver.received()[0]), &msg); err != nil {
+		t.Fatalf("invalid JSON body: %v", err)
+	}
+	if msg.Text != "🟥 *AU:* testing → testfail" || len(msg.Blocks) == 0 {
+		t.Errorf("unexpected Slack message %+v", msg)
+	}
+}
+
+func TestWebhook_TemplateAndHeaders(t *testing.T) {
+	receiver := newWebhookReceiver(t)
+	d, _ := newTestDispatcher(t, WebhookConfig{
+		URL:      receiver.server.URL,
+		Template: `{"content": {{json .Text}}, "region": "{{.Region}}"}`,
+		Headers:  map[string]string{"Authorization": "Bearer secret"},
+	})
+
+	d.deliver(context.Background(), d.targets[0], transitionEvent(testTransition))
+
+	if body := receiver.received()[0]; body != `{"content": "AU: testing → testfail", "region": "au"}` {
+		t.Errorf("unexpected body %s", body)
+	}
+	if auth := receiver.requests[0].Header.Get("Authorization"); auth != "Bearer secret" {
+		t.Errorf("expected Authorization header, got %q", auth)
+	}
+}
+
+func TestWebhook_RetriesTransientFailures(t *testing.T) {
+	receiver := newWebhookReceiver(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	d, logPath := newTestDispatcher(t, WebhookConfig{Name: "ops", URL: receiver.server.URL})
+
+	if !d.deliver(context.Background(), d.targets[0], transitionEvent(testTransition)) {
+		t.Fatal("expected delivery to succeed on the third attempt")
+	}
+
+	deliveries := readDeliveries(t, logPath)
+	if len(deliveries) != 3 {
+		t.Fatalf("expected 3 logged attempts, got %+v", deliveries)
+	}
+	for i, code := range []int{503, 429, 200} {
+		got := deliveries[i]
+		if got.Webhook != "ops" || got.Transition != 7 || got.Attempt != i+1 || got.StatusCode != code {
+			t.Errorf("attempt %d: unexpected delivery %+v", i+1, got)
+		}
+		if got.Delivered != (code == 200) {
+			t.Errorf("attempt %d: expected delivered=%v", i+1, code == 200)
+		}
+	}
+	if !strings.Contains(deliveries[0].Error, "HTTP 503") {
+		t.Errorf("expected error to be logged, got %q", deliveries[0].Error)
+	}
+}
+
+func TestWebhook_DoesNotRetryClientErrors(t *testing.T) {
+	receiver := newWebhookReceiver(t, http.StatusBadRequest)
+	d, logPath := newTestDispatcher(t, WebhookConfig{URL: receiver.server.URL})
+
+	if d.deliver(context.Background(), d.targets[0], transitionEvent(testTransition)) {
+		t.Fatal("expected delivery to fail")
+	}
+	if got := len(receiver.received()); got != 1 {
+		t.Errorf("expected 1 request, got %d", got)
+	}
+	if deliveries := readDeliveries(t, logPath); len(deliveries) != 1 || deliveries[0].Delivered {
+		t.Errorf("expected one failed delivery logged, got %+v", deliveries)
+	}
+}
+
+func TestWebhook_GivesUpAfterMaxAttempts(t *testing.T) {
+	receiver := newWebhookReceiver(t, 500, 500, 500, 500)
+	d, _ := newTestDispatcher(t, WebhookConfig{URL: receiver.server.URL})
+
+	if d.deliver(context.Background(), d.targets[0], transitionEvent(testTransition)) {
+		t.Fatal("expected delivery to fail")
+	}
+	if got := len(receiver.received()); got != 3 {
+		t.Errorf("expected 3 attempts, got %d", got)
+	}
+}
+
+func TestWebhookDispatcher_DeliversTransitionsFromUpdateAll(t *testing.T) {
+	all := newWebhookReceiver(t)
+	usOnly := newWebhookReceiver(t)
+	d, _ := newTestDispatcher(t,
+		WebhookConfig{URL: all.server.URL},
+		WebhookConfig{URL: usOnly.server.URL, Regions: []string{"us"}},
+	)
+
+	cache := NewStatusCacheWithPath(filepath.Join(t.TempDir(), "statuses.json"))
+	cache.OnTransitions(d.enqueue)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.run(ctx)
+
+	// First observations aren't sent, then each change is sent in order
+	cache.UpdateAll(map[string]statusResult{"au": {region: "au", status: "complete"}, "us": {region: "us", status: "complete"}})
+	cache.UpdateAll(map[string]statusResult{"au": {region: "au", status: "testing"}, "us": {region: "us", status: "complete"}})
+	cache.UpdateAll(map[string]statusResult{"au": {region: "au", status: "testok"}, "us": {region: "us", status: "testing"}})
+
+	var texts []string
+	for _, body := range all.waitForBodies(t, 3) {
+		var payload webhookPayload
+		json.Unmarshal([]byte(body), &payload)
+		texts = append(texts, payload.Text)
+	}
+	expected := []string{"AU: complete → testing", "AU: testing → testok", "US: complete → testing"}
+	if strings.Join(texts, "|") != strings.Join(expected, "|") {
+		t.Errorf("expected %v, got %v", expected, texts)
+	}
+
+	if bodies := usOnly.waitForBodies(t, 1); len(bodies) != 1 || !strings.Contains(bodies[0], `"region":"us"`) {
+		t.Errorf("expected only the us transition, got %v", bodies)
+	}
+}
+
+func TestWebhookDispatcher_DropsWhenQueueFull(t *testing.T) {
+	d, logPath := newTestDispatcher(t, WebhookConfig{Name: "slow", URL: "http://127.0.0.1:1"})
+
+	// Nothing is running, so the queue fills up
+	for i := 0; i <= webhookQueueSize; i++ {
+		d.enqueue([]transition{{ID: int64(i + 1), Region: "au", From: "testing", To: "testok"}})
+	}
+
+	deliveries := readDeliveries(t, logPath)
+	if len(deliveries) != 1 || deliveries[0].Transition != webhookQueueSize+1 || !strings.Contains(deliveries[0].Error, "queue full") {
+		t.Errorf("expected the overflowing transition to be logged as dropped, got %+v", deliveries)
+	}
+}
+
+func TestWebhookDispatcher_DrainsQueueOnShutdown(t *testing.T) {
+	receiver := newWebhookReceiver(t)
+	d, logPath := newTestDispatcher(t, WebhookConfig{URL: receiver.server.URL})
+
+	// Recorded just before shutdown, so still queued when ctx is done
+	d.enqueue([]transition{testTransition, {ID: 8, Region: "au", From: "testfail", To: "testing"}})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	d.run(ctx)
+
+	if bodies := receiver.received(); len(bodies) != 2 {
+		t.Errorf("expected both queued transitions to be delivered, got %d", len(bodies))
+	}
+	if deliveries := readDeliveries(t, logPath); len(deliveries) != 2 || !deliveries[1].Delivered {
+		t.Errorf("expected both deliveries in the log, got %+v", deliveries)
+	}
+}
+
+func TestValidateWebhook(t *testing.T) {
+	known := func(region string) bool { return region == "au" }
+
+	w := WebhookConfig{URL: "https://chat.example.com/hooks/123"}
+	if err := validateWebhook(&w, known); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if w.Name != "chat.example.com" || w.Format != webhookFormatJSON {
+		t.Errorf("expected defaults to be filled in, got %+v", w)
+	}
+
+	invalid := map[string]WebhookConfig{
+		"missing url":    {},
+		"ftp url":        {URL: "ftp://example.com"},
+		"unknown format": {URL: "https://example.com", Format: "xml"},
+		"bad template":   {URL: "https://example.com", Template: "{{.Text"},
+		"unknown region": {URL: "https://example.com", Regions: []string{"eu"}},
+	}
+	for name, w := range invalid {
+		if err := validateWebhook(&w, known); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestWebhook_AlertPayload(t *testing.T) {
+	receiver := newWebhookReceiver(t)
+	d, _ := newTestDispatcher(t, WebhookConfig{URL: receiver.server.URL}, WebhookConfig{URL: receiver.server.URL, Format: webhookFormatSlack})
+
+	ev := alertEvent{
+		Rule:      "deploy-stuck",
+		Region:    "overall",
+		State:     alertFiring,
+		Condition: "is not complete",
+		Status:    "testing",
+		Since:     testTransition.ChangedAt.Add(-time.Hour),
+		At:        testTransition.ChangedAt,
+	}
+	d.deliver(context.Background(), d.targets[0], alertWebhookEvent(ev))
+	d.deliver(context.Background(), d.targets[1], alertWebhookEvent(ev))
+
+	bodies := receiver.received()
+	var payload webhookPayload
+	if err := json.Unmarshal([]byte(bodies[0]), &payload); err != nil {
+		t.Fatalf("invalid JSON body: %v", err)
+	}
+	expected := webhookPayload{
+		Event:     "alert",
+		Region:    "overall",
+		Label:     "Status",
+		Alert:     "deploy-stuck",
+		State:     alertFiring,
+		Class:     classRed,
+		ChangedAt: ev.At,
+		Text:      "deploy-stuck firing: Status is not complete for 1h0m0s (testing)",
+	}
+	if payload != expected {
+		t.Errorf("expected %+v, got %+v", expected, payload)
+	}
+
+	var msg slackMessage
+	json.Unmarshal([]byte(bodies[1]), &msg)
+	if msg.Text != "🚨 *deploy-stuck* firing: Status is not complete for 1h0m0s (testing)" {
+		t.Errorf("unexpected Slack message %q", msg.Text)
+	}
+}
+
+func TestStartWebhooks_DeliversTransitionsRecordedByAnotherProcess(t *testing.T) {
+	receiver := newWebhookReceiver(t)
+	withConfig(t, func(cfg *Config) {
+		cfg.Webhooks = []WebhookConfig{{Name: "test", URL: receiver.server.URL}}
+	})
+
+	dir := t.TempDir()
+	cache := NewStatusCacheWithPath(filepath.Join(dir, "statuses.json"))
+	cache.UpdateAll(map[string]statusResult{"au": {region: "au", status: "testing"}})
+	lease := NewLease(filepath.Join(dir, "fetcher.lease"), leaseTTL)
+	lease.TryAcquire()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, wait, err := startWebhooks(ctx, cache, lease)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A one-shot check sees the change first, so the fetcher's next fetch records nothing
+	other := NewStatusCacheWithPath(cache.filePath)
+	other.UpdateAll(map[string]statusResult{"au": {region: "au", status: "complete"}})
+	cache.Reload()
+	cache.UpdateAll(map[string]statusResult{"au": {region: "au", status: "complete"}})
+
+	var bodies []string
+	for deadline := time.Now().Add(2*eventsPollInterval + time.Second); len(bodies) == 0 && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		bodies = receiver.received()
+	}
+	cancel()
+	wait()
+
+	if len(bodies) != 1 || !strings.Contains(bodies[0], "AU: testing → complete") {
+		t.Errorf("expected the other process's transition to be delivered once, got %v", bodies)
+	}
+}